@@ -0,0 +1,172 @@
+package skynet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultStrategy is the allocation strategy used when none is chosen.
+const DefaultStrategy = "first-fit"
+
+// AllocationStrategy decides which nodes supply units for a mission and
+// which nodes get recovered units back. Both methods mutate Deployed in
+// place and return the number of units actually moved.
+type AllocationStrategy interface {
+	Name() string
+	Consume(nodes []Node, units int) int
+	Recover(nodes []Node, units int) int
+}
+
+var (
+	strategies      = map[string]AllocationStrategy{}
+	strategyAliases = map[string]string{}
+)
+
+// RegisterStrategy adds a strategy to the registry under its name and any
+// aliases. Names may only be registered once.
+func RegisterStrategy(s AllocationStrategy, aliases ...string) {
+	for _, name := range append([]string{s.Name()}, aliases...) {
+		key := strings.ToLower(name)
+		if _, exists := strategyAliases[key]; exists {
+			panic(fmt.Sprintf("skynet: duplicate allocation strategy %q", name))
+		}
+		strategyAliases[key] = s.Name()
+	}
+	strategies[s.Name()] = s
+}
+
+// LookupStrategy resolves a strategy by name or alias; an empty name
+// returns the default.
+func LookupStrategy(name string) (AllocationStrategy, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultStrategy
+	}
+	canonical, ok := strategyAliases[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown allocation strategy %q (available: %s)", name, strings.Join(StrategyNames(), ", "))
+	}
+	return strategies[canonical], nil
+}
+
+// StrategyNames lists the registered strategy names.
+func StrategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterStrategy(firstFit{})
+	RegisterStrategy(scoredStrategy{
+		name: "balanced",
+		// D'Hondt-style split proportional to free capacity.
+		consume: func(n Node, taken int) float64 { return float64(NodeAvailable(n)) / float64(taken+1) },
+		recover: func(n Node, given int) float64 { return float64(n.Deployed) / float64(given+1) },
+	}, "proportional")
+	RegisterStrategy(scoredStrategy{
+		name:    "least-loaded",
+		consume: func(n Node, taken int) float64 { return -loadRatio(n.Deployed+taken, n.Capacity) },
+		recover: func(n Node, given int) float64 { return loadRatio(n.Deployed-given, n.Capacity) },
+	})
+	RegisterStrategy(scoredStrategy{
+		name:    "most-available",
+		consume: func(n Node, taken int) float64 { return float64(NodeAvailable(n) - taken) },
+		recover: func(n Node, given int) float64 { return float64(n.Deployed - given) },
+	})
+	RegisterStrategy(scoredStrategy{
+		name:    "weighted",
+		consume: func(n Node, taken int) float64 { return priorityWeight(n) / float64(taken+1) },
+		recover: func(n Node, given int) float64 { return priorityWeight(n) / float64(given+1) },
+	}, "priority")
+}
+
+// firstFit is the original strict slice-order allocation.
+type firstFit struct{}
+
+func (firstFit) Name() string                        { return DefaultStrategy }
+func (firstFit) Consume(nodes []Node, units int) int { return consumeUnits(nodes, units) }
+func (firstFit) Recover(nodes []Node, units int) int { return recoverUnits(nodes, units) }
+
+// scoredStrategy hands out units one at a time to the eligible node with
+// the highest score; ties go to the earlier node. The score functions see
+// how many units that node has already taken or been given in this call.
+type scoredStrategy struct {
+	name    string
+	consume func(n Node, taken int) float64
+	recover func(n Node, given int) float64
+}
+
+func (s scoredStrategy) Name() string { return s.name }
+
+func (s scoredStrategy) Consume(nodes []Node, units int) int {
+	moved := allocateUnits(nodes, units, func(n Node, k int) bool { return NodeAvailable(n) > k }, s.consume)
+	for i := range nodes {
+		nodes[i].Deployed += moved[i]
+	}
+	return sum(moved)
+}
+
+// Recover refills draining nodes first, as recoverUnits does, then the
+// rest of the fleet.
+func (s scoredStrategy) Recover(nodes []Node, units int) int {
+	total := 0
+	for _, draining := range []bool{true, false} {
+		eligible := func(n Node, k int) bool { return n.Draining == draining && n.Deployed > k }
+		moved := allocateUnits(nodes, units-total, eligible, s.recover)
+		for i := range nodes {
+			nodes[i].Deployed -= moved[i]
+		}
+		total += sum(moved)
+	}
+	return total
+}
+
+func allocateUnits(nodes []Node, units int, eligible func(Node, int) bool, score func(Node, int) float64) []int {
+	moved := make([]int, len(nodes))
+	for ; units > 0; units-- {
+		best := -1
+		bestScore := 0.0
+		for i, n := range nodes {
+			if !eligible(n, moved[i]) {
+				continue
+			}
+			if sc := score(n, moved[i]); best < 0 || sc > bestScore {
+				best, bestScore = i, sc
+			}
+		}
+		if best < 0 {
+			break
+		}
+		moved[best]++
+	}
+	return moved
+}
+
+func loadRatio(deployed, capacity int) float64 {
+	if capacity <= 0 {
+		return 1
+	}
+	return float64(deployed) / float64(capacity)
+}
+
+// priorityWeight treats priorities below 1 as 1 so unprioritized nodes
+// still take a share.
+func priorityWeight(n Node) float64 {
+	if n.Priority < 1 {
+		return 1
+	}
+	return float64(n.Priority)
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,64 @@
+package skynet
+
+import "testing"
+
+func deployedOf(nodes []Node) [3]int {
+	return [3]int{nodes[0].Deployed, nodes[1].Deployed, nodes[2].Deployed}
+}
+
+func TestAllocationStrategies(t *testing.T) {
+	cases := []struct {
+		name      string
+		consumed  [3]int
+		recovered [3]int
+	}{
+		{"first-fit", [3]int{4, 6, 4}, [3]int{0, 4, 4}},
+		{"balanced", [3]int{2, 6, 6}, [3]int{1, 3, 4}},
+		{"least-loaded", [3]int{3, 7, 4}, [3]int{1, 4, 3}},
+		{"most-available", [3]int{1, 9, 4}, [3]int{1, 3, 4}},
+		{"weighted", [3]int{2, 6, 6}, [3]int{1, 2, 5}},
+	}
+	for _, tc := range cases {
+		strategy, err := LookupStrategy(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := []Node{
+			{Name: "a", Capacity: 4},
+			{Name: "b", Capacity: 12, Priority: 3},
+			{Name: "c", Capacity: 8, Deployed: 4},
+		}
+		if got := strategy.Consume(nodes, 10); got != 10 || deployedOf(nodes) != tc.consumed {
+			t.Fatalf("%s consume: moved=%d deployed=%v want %v", tc.name, got, deployedOf(nodes), tc.consumed)
+		}
+		if got := strategy.Recover(nodes, 6); got != 6 || deployedOf(nodes) != tc.recovered {
+			t.Fatalf("%s recover: moved=%d deployed=%v want %v", tc.name, got, deployedOf(nodes), tc.recovered)
+		}
+	}
+}
+
+func TestScoredStrategyRespectsDraining(t *testing.T) {
+	strategy, _ := LookupStrategy("proportional")
+	nodes := []Node{{Name: "a", Capacity: 5, Deployed: 2, Draining: true}, {Name: "b", Capacity: 5, Deployed: 3}}
+	if got := strategy.Consume(nodes, 9); got != 2 || nodes[0].Deployed != 2 {
+		t.Fatalf("draining node supplied units: moved=%d nodes=%+v", got, nodes)
+	}
+	strategy.Recover(nodes, 3)
+	if nodes[0].Deployed != 0 || nodes[1].Deployed != 4 {
+		t.Fatalf("recovery should empty draining node first: %+v", nodes)
+	}
+}
+
+func TestDispatchRecordsStrategy(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	mission, err := Dispatch(&st, "hq", 2)
+	if err != nil || mission.Strategy != DefaultStrategy {
+		t.Fatalf("unexpected mission: %+v err=%v", mission, err)
+	}
+	if _, err := DispatchWith(&st, "hq", 2, DispatchOptions{Strategy: "bogus"}); err == nil {
+		t.Fatal("expected unknown strategy error")
+	}
+}
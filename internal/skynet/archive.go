@@ -0,0 +1,269 @@
+package skynet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const archiveDir = "archive"
+
+// RetentionPolicy bounds the missions kept in the hot state. Missions beyond
+// the newest KeepMissions, or older than KeepDays, move to the archive. Zero
+// disables the respective limit.
+type RetentionPolicy struct {
+	KeepMissions int `json:"keep_missions"`
+	KeepDays     int `json:"keep_days"`
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepMissions > 0 || p.KeepDays > 0
+}
+
+func (p RetentionPolicy) validate() error {
+	if p.KeepMissions < 0 {
+		return fmt.Errorf("retention.keep_missions must be >= 0")
+	}
+	if p.KeepDays < 0 {
+		return fmt.Errorf("retention.keep_days must be >= 0")
+	}
+	return nil
+}
+
+type ArchiveSegment struct {
+	Path     string `json:"path"`
+	Missions int    `json:"missions"`
+}
+
+// MissionArchive stores rotated missions as gzip-compressed JSONL segments.
+// Segments are only ever added, one per rotation.
+type MissionArchive struct {
+	Dir string
+	// Key encrypts segments at rest when set.
+	Key *StateKey
+}
+
+func NewMissionArchive(statePath string) MissionArchive {
+	return MissionArchive{Dir: filepath.Join(filepath.Dir(statePath), archiveDir)}
+}
+
+func (a MissionArchive) Segments() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(a.Dir, "missions-*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Append writes missions to a new segment.
+func (a MissionArchive) Append(missions []Mission) (ArchiveSegment, error) {
+	if len(missions) == 0 {
+		return ArchiveSegment{}, nil
+	}
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return ArchiveSegment{}, err
+	}
+	path, err := a.reserveSegment()
+	if err != nil {
+		return ArchiveSegment{}, err
+	}
+
+	var buf strings.Builder
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, m := range missions {
+		if err := enc.Encode(m); err != nil {
+			return ArchiveSegment{}, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return ArchiveSegment{}, err
+	}
+	if err := writeSealedFile(path, []byte(buf.String()), a.Key); err != nil {
+		return ArchiveSegment{}, err
+	}
+	return ArchiveSegment{Path: path, Missions: len(missions)}, nil
+}
+
+// reserveSegment creates the next segment file, numbered one past the
+// highest existing segment, exclusively and empty so that no existing
+// segment is ever overwritten. Append then fills it in atomically.
+func (a MissionArchive) reserveSegment() (string, error) {
+	segments, err := a.Segments()
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	for _, path := range segments {
+		var n int
+		if _, err := fmt.Sscanf(filepath.Base(path), "missions-%d.jsonl.gz", &n); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	for {
+		path := filepath.Join(a.Dir, fmt.Sprintf("missions-%06d.jsonl.gz", next))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			next++
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// Missions reads every archived mission, oldest segment first.
+func (a MissionArchive) Missions() ([]Mission, error) {
+	segments, err := a.Segments()
+	if err != nil {
+		return nil, err
+	}
+	missions := []Mission{}
+	for _, path := range segments {
+		segment, err := readArchiveSegment(path, a.Key)
+		if err != nil {
+			return nil, err
+		}
+		missions = append(missions, segment...)
+	}
+	return missions, nil
+}
+
+// RotateMissions moves the missions that fall outside policy from st into the
+// archive and returns how many were moved. The segment is written before st
+// is changed, so a crash in between only leaves duplicates, which
+// IncludeArchived ignores.
+func RotateMissions(st *State, archive MissionArchive, policy RetentionPolicy, at time.Time) (int, error) {
+	if !policy.Enabled() || len(st.Missions) == 0 {
+		return 0, nil
+	}
+
+	cut := 0
+	if policy.KeepMissions > 0 && len(st.Missions) > policy.KeepMissions {
+		cut = len(st.Missions) - policy.KeepMissions
+	}
+	if policy.KeepDays > 0 {
+		cutoff := at.Add(-time.Duration(policy.KeepDays) * 24 * time.Hour)
+		for cut < len(st.Missions) {
+			created, err := time.Parse(time.RFC3339, st.Missions[cut].CreatedAt)
+			if err != nil || !created.Before(cutoff) {
+				break
+			}
+			cut++
+		}
+	}
+	for i := 0; i < cut; i++ {
+		if st.Missions[i].InFlight() {
+			// In-flight missions still hold units; keep them and everything
+			// after them hot.
+			cut = i
+			break
+		}
+	}
+	if cut == 0 {
+		return 0, nil
+	}
+
+	moved := append([]Mission(nil), st.Missions[:cut]...)
+	if _, err := archive.Append(moved); err != nil {
+		return 0, err
+	}
+	ids := make([]string, 0, len(moved))
+	for _, m := range moved {
+		ids = append(ids, m.ID)
+	}
+	st.Missions = append([]Mission{}, st.Missions[cut:]...)
+	st.record(EventMissionsArchived, at.UTC().Format(time.RFC3339), MissionsArchivedEvent{IDs: ids})
+	return cut, nil
+}
+
+// IncludeArchived returns a copy of st whose Missions also contain every
+// archived mission, oldest first.
+func IncludeArchived(st State, archive MissionArchive) (State, error) {
+	archived, err := archive.Missions()
+	if err != nil {
+		return State{}, err
+	}
+	hot := make(map[string]bool, len(st.Missions))
+	for _, m := range st.Missions {
+		hot[m.ID] = true
+	}
+	all := make([]Mission, 0, len(archived)+len(st.Missions))
+	for _, m := range archived {
+		if !hot[m.ID] {
+			hot[m.ID] = true
+			all = append(all, m)
+		}
+	}
+	st.Missions = append(all, st.Missions...)
+	return st, nil
+}
+
+// ArchivingStore rotates missions according to Policy on every save.
+type ArchivingStore struct {
+	Store
+	Archive MissionArchive
+	Policy  RetentionPolicy
+}
+
+func WithMissionArchive(inner Store, statePath string, policy RetentionPolicy) ArchivingStore {
+	archive := NewMissionArchive(statePath)
+	archive.Key = storeKey(inner)
+	return ArchivingStore{Store: inner, Archive: archive, Policy: policy}
+}
+
+func (s ArchivingStore) Save(st State) error {
+	st.Missions = append([]Mission(nil), st.Missions...)
+	if _, err := RotateMissions(&st, s.Archive, s.Policy, st.Now()); err != nil {
+		return fmt.Errorf("rotate missions: %w", err)
+	}
+	return s.Store.Save(st)
+}
+
+func readArchiveSegment(path string, key *StateKey) ([]Mission, error) {
+	data, err := readSealedFile(path, key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Mission{}, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		// A segment reserved by an Append that has not written it yet.
+		return []Mission{}, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	missions := []Mission{}
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var m Mission
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		missions = append(missions, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return missions, nil
+}
@@ -0,0 +1,121 @@
+package skynet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateMissionsKeepCount(t *testing.T) {
+	archive := NewMissionArchive(filepath.Join(t.TempDir(), "state.json"))
+	st := NewState()
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "alpha", Consumed: 2, NetLoss: 1, Outcome: "CONTAINED", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-2", Target: "alpha", Consumed: 3, NetLoss: 2, Outcome: "CONTAINED", CreatedAt: "2026-01-20T00:00:00Z"},
+		{ID: "M-3", Target: "beta", Consumed: 1, NetLoss: 0, Outcome: "NEUTRALIZED", CreatedAt: "2026-02-01T00:00:00Z"},
+	}
+
+	moved, err := RotateMissions(&st, archive, RetentionPolicy{KeepMissions: 1}, time.Now())
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if moved != 2 || len(st.Missions) != 1 || st.Missions[0].ID != "M-3" {
+		t.Fatalf("unexpected rotation: moved=%d hot=%+v", moved, st.Missions)
+	}
+
+	all, err := IncludeArchived(st, archive)
+	if err != nil {
+		t.Fatalf("include archived: %v", err)
+	}
+	if len(all.Missions) != 3 || all.Missions[0].ID != "M-1" || all.Missions[2].ID != "M-3" {
+		t.Fatalf("expected archived missions first, got %+v", all.Missions)
+	}
+	report, err := BuildMissionReport(all, 0)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if report.TotalMissions != 3 || report.TotalNetLoss != 3 || report.MostTargeted != "alpha" {
+		t.Fatalf("report did not read across archive: %+v", report)
+	}
+}
+
+func TestRotateMissionsKeepDays(t *testing.T) {
+	archive := NewMissionArchive(filepath.Join(t.TempDir(), "state.json"))
+	st := NewState()
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "alpha", Consumed: 2, NetLoss: 1, Outcome: "CONTAINED", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-2", Target: "alpha", Consumed: 3, NetLoss: 2, Outcome: "CONTAINED", CreatedAt: "2026-01-20T00:00:00Z"},
+		{ID: "M-3", Target: "beta", Consumed: 1, NetLoss: 0, Outcome: "NEUTRALIZED", CreatedAt: "2026-02-01T00:00:00Z"},
+	}
+	at := time.Date(2026, 2, 5, 0, 0, 0, 0, time.UTC)
+
+	moved, err := RotateMissions(&st, archive, RetentionPolicy{KeepDays: 10}, at)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if moved != 2 || st.Missions[0].ID != "M-3" {
+		t.Fatalf("unexpected rotation: moved=%d hot=%+v", moved, st.Missions)
+	}
+	segments, err := archive.Segments()
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected one segment, got %v (%v)", segments, err)
+	}
+}
+
+func TestIncludeArchivedSkipsDuplicates(t *testing.T) {
+	archive := NewMissionArchive(filepath.Join(t.TempDir(), "state.json"))
+	st := NewState()
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "alpha", Consumed: 2, NetLoss: 1, Outcome: "CONTAINED", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-2", Target: "alpha", Consumed: 3, NetLoss: 2, Outcome: "CONTAINED", CreatedAt: "2026-01-20T00:00:00Z"},
+		{ID: "M-3", Target: "beta", Consumed: 1, NetLoss: 0, Outcome: "NEUTRALIZED", CreatedAt: "2026-02-01T00:00:00Z"},
+	}
+	if _, err := archive.Append(st.Missions[:2]); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	all, err := IncludeArchived(st, archive)
+	if err != nil {
+		t.Fatalf("include archived: %v", err)
+	}
+	if len(all.Missions) != 3 {
+		t.Fatalf("expected duplicates to be skipped, got %d missions", len(all.Missions))
+	}
+}
+
+func TestArchiveAppendNeverReusesSegmentNumbers(t *testing.T) {
+	archive := NewMissionArchive(filepath.Join(t.TempDir(), "state.json"))
+	missions := []Mission{
+		{ID: "M-1", Target: "alpha", Consumed: 2, NetLoss: 1, Outcome: "CONTAINED", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-2", Target: "alpha", Consumed: 3, NetLoss: 2, Outcome: "CONTAINED", CreatedAt: "2026-01-20T00:00:00Z"},
+		{ID: "M-3", Target: "beta", Consumed: 1, NetLoss: 0, Outcome: "NEUTRALIZED", CreatedAt: "2026-02-01T00:00:00Z"},
+	}
+	for i := range missions {
+		if _, err := archive.Append(missions[i : i+1]); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	// A pruned first segment must not make the next append reuse "3".
+	if err := os.Remove(filepath.Join(archive.Dir, "missions-000001.jsonl.gz")); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archive.Append(missions[:1])
+	if err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if filepath.Base(segment.Path) != "missions-000004.jsonl.gz" {
+		t.Fatalf("unexpected segment %s", segment.Path)
+	}
+	// An empty segment is one reserved by an unfinished append.
+	if err := os.WriteFile(filepath.Join(archive.Dir, "missions-000005.jsonl.gz"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	all, err := archive.Missions()
+	if err != nil {
+		t.Fatalf("missions: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != "M-2" || all[2].ID != "M-1" {
+		t.Fatalf("unexpected archived missions %+v", all)
+	}
+}
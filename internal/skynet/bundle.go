@@ -0,0 +1,294 @@
+package skynet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const BundleFormat = "skynet-bundle/1"
+
+type BundleMetadata struct {
+	Workspace     string `json:"workspace"`
+	SchemaVersion int    `json:"schema_version"`
+	CoreVersion   string `json:"core_version"`
+	Nodes         int    `json:"nodes"`
+	Targets       int    `json:"targets"`
+	Missions      int    `json:"missions"`
+}
+
+// Bundle is a self-contained, checksummed export of one State.
+type Bundle struct {
+	Format     string          `json:"format"`
+	ExportedAt string          `json:"exported_at"`
+	Metadata   BundleMetadata  `json:"metadata"`
+	Checksum   string          `json:"checksum"`
+	State      json.RawMessage `json:"state"`
+}
+
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictKeep      ConflictPolicy = "keep"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictRename    ConflictPolicy = "rename"
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case ConflictFail, ConflictKeep, ConflictOverwrite, ConflictRename:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want fail, keep, overwrite or rename)", s)
+}
+
+type MergeResult struct {
+	NodesAdded       int      `json:"nodes_added"`
+	NodesUpdated     int      `json:"nodes_updated"`
+	NodesSkipped     int      `json:"nodes_skipped"`
+	TargetsAdded     int      `json:"targets_added"`
+	TargetsUpdated   int      `json:"targets_updated"`
+	TargetsSkipped   int      `json:"targets_skipped"`
+	MissionsAdded    int      `json:"missions_added"`
+	MissionsSkipped  int      `json:"missions_skipped"`
+	MissionsUpdated  int      `json:"missions_updated"`
+	Renamed          []string `json:"renamed"`
+	ConflictingNames []string `json:"conflicting_names"`
+	// MissionConflicts lists imported missions whose ID is taken by a
+	// different mission (another target or creation time).
+	MissionConflicts []string `json:"mission_conflicts"`
+}
+
+func NewBundle(st State, workspace string) (Bundle, error) {
+	data, err := canonicalState(st)
+	if err != nil {
+		return Bundle{}, err
+	}
+	return Bundle{
+		Format:     BundleFormat,
+		ExportedAt: st.timestamp(),
+		Metadata: BundleMetadata{
+			Workspace:     workspace,
+			SchemaVersion: CurrentSchemaVersion,
+			CoreVersion:   st.Core.Version,
+			Nodes:         len(st.Nodes),
+			Targets:       len(st.Targets),
+			Missions:      len(st.Missions),
+		},
+		Checksum: bundleChecksum(data),
+		State:    data,
+	}, nil
+}
+
+// WriteBundle writes b as gzip-compressed JSON.
+func WriteBundle(w io.Writer, b Bundle) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadBundle reads a bundle written by WriteBundle. Uncompressed JSON is
+// accepted as well so bundles can be inspected and edited by hand.
+func ReadBundle(r io.Reader) (Bundle, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return Bundle{}, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+	var b Bundle
+	if err := json.NewDecoder(src).Decode(&b); err != nil {
+		return Bundle{}, fmt.Errorf("read bundle: %w", err)
+	}
+	if b.Format != BundleFormat {
+		return Bundle{}, fmt.Errorf("unsupported bundle format %q (want %s)", b.Format, BundleFormat)
+	}
+	return b, nil
+}
+
+// Decode verifies the bundle checksum and returns its state, migrated to
+// the current schema.
+func (b Bundle) Decode() (State, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, b.State); err != nil {
+		return State{}, fmt.Errorf("bundle state: %w", err)
+	}
+	if got := bundleChecksum(compact.Bytes()); got != b.Checksum {
+		return State{}, fmt.Errorf("bundle checksum mismatch: recorded %s, computed %s", b.Checksum, got)
+	}
+	return decodeState(compact.Bytes())
+}
+
+// MergeState merges nodes, targets and missions from src into dst. Names are
+// matched case-insensitively, as AddNode and AddTarget do; policy decides what
+// happens to duplicates. A mission with the same ID, target and creation time
+// is the same mission and is skipped; a different mission reusing an ID is a
+// conflict that policy resolves like a name clash. Missions are kept in time
+// order.
+func MergeState(dst *State, src State, policy ConflictPolicy) (MergeResult, error) {
+	result := MergeResult{Renamed: []string{}, ConflictingNames: []string{}, MissionConflicts: []string{}}
+	existing := make(map[string]int, len(dst.Missions))
+	for i, m := range dst.Missions {
+		existing[m.ID] = i
+	}
+	for _, m := range src.Missions {
+		if i, ok := existing[m.ID]; ok && !sameMission(dst.Missions[i], m) {
+			result.MissionConflicts = append(result.MissionConflicts, m.ID)
+		}
+	}
+	if policy == ConflictFail {
+		for _, n := range src.Nodes {
+			if findNodeIndex(dst.Nodes, n.Name) >= 0 {
+				result.ConflictingNames = append(result.ConflictingNames, "node "+n.Name)
+			}
+		}
+		for _, t := range src.Targets {
+			if findTargetIndex(dst.Targets, t.Name) >= 0 {
+				result.ConflictingNames = append(result.ConflictingNames, "target "+t.Name)
+			}
+		}
+		for _, id := range result.MissionConflicts {
+			result.ConflictingNames = append(result.ConflictingNames, "mission "+id)
+		}
+		if len(result.ConflictingNames) > 0 {
+			return result, fmt.Errorf("import conflicts with existing %s", strings.Join(result.ConflictingNames, ", "))
+		}
+	}
+
+	nodeNames := map[string]string{}
+	for _, n := range src.Nodes {
+		idx := findNodeIndex(dst.Nodes, n.Name)
+		switch {
+		case idx < 0:
+			dst.Nodes = append(dst.Nodes, n)
+			result.NodesAdded++
+		case policy == ConflictOverwrite:
+			n.Name = dst.Nodes[idx].Name
+			dst.Nodes[idx] = n
+			result.NodesUpdated++
+		case policy == ConflictRename:
+			renamed := uniqueName(n.Name, func(name string) bool { return findNodeIndex(dst.Nodes, name) >= 0 })
+			result.Renamed = append(result.Renamed, fmt.Sprintf("node %s -> %s", n.Name, renamed))
+			nodeNames[strings.ToLower(n.Name)] = renamed
+			n.Name = renamed
+			dst.Nodes = append(dst.Nodes, n)
+			result.NodesAdded++
+		default:
+			result.NodesSkipped++
+		}
+	}
+
+	targetNames := map[string]string{}
+	for _, t := range src.Targets {
+		idx := findTargetIndex(dst.Targets, t.Name)
+		switch {
+		case idx < 0:
+			dst.Targets = append(dst.Targets, t)
+			result.TargetsAdded++
+		case policy == ConflictOverwrite:
+			t.Name = dst.Targets[idx].Name
+			dst.Targets[idx] = t
+			result.TargetsUpdated++
+		case policy == ConflictRename:
+			renamed := uniqueName(t.Name, func(name string) bool { return findTargetIndex(dst.Targets, name) >= 0 })
+			result.Renamed = append(result.Renamed, fmt.Sprintf("target %s -> %s", t.Name, renamed))
+			targetNames[strings.ToLower(t.Name)] = renamed
+			t.Name = renamed
+			dst.Targets = append(dst.Targets, t)
+			result.TargetsAdded++
+		default:
+			result.TargetsSkipped++
+		}
+	}
+
+	for _, m := range src.Missions {
+		idx, taken := existing[m.ID]
+		if taken && (sameMission(dst.Missions[idx], m) || policy == ConflictKeep) {
+			result.MissionsSkipped++
+			continue
+		}
+		if renamed, ok := targetNames[strings.ToLower(m.Target)]; ok {
+			m.Target = renamed
+		}
+		m.Assignments = append([]NodeAssignment(nil), m.Assignments...)
+		for i, a := range m.Assignments {
+			if renamed, ok := nodeNames[strings.ToLower(a.Node)]; ok {
+				m.Assignments[i].Node = renamed
+			}
+		}
+		if taken {
+			if policy == ConflictOverwrite {
+				dst.Missions[idx] = m
+				result.MissionsUpdated++
+				continue
+			}
+			renamed := uniqueName(m.ID, func(id string) bool { _, ok := existing[id]; return ok })
+			result.Renamed = append(result.Renamed, fmt.Sprintf("mission %s -> %s", m.ID, renamed))
+			m.ID = renamed
+		}
+		existing[m.ID] = len(dst.Missions)
+		dst.Missions = append(dst.Missions, m)
+		result.MissionsAdded++
+	}
+	sort.SliceStable(dst.Missions, func(i, j int) bool { return dst.Missions[i].CreatedAt < dst.Missions[j].CreatedAt })
+	if n := len(dst.Missions); n > 0 && dst.Missions[n-1].CreatedAt > dst.Core.LastMission {
+		dst.Core.LastMission = dst.Missions[n-1].CreatedAt
+	}
+	dst.Core.MissionSeq = max(dst.Core.MissionSeq, src.Core.MissionSeq)
+	return result, nil
+}
+
+// sameMission reports whether two missions sharing an ID are one mission
+// seen in two states rather than two missions that happen to share an ID.
+func sameMission(a, b Mission) bool {
+	return strings.EqualFold(a.Target, b.Target) && a.CreatedAt == b.CreatedAt
+}
+
+func bundleChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func uniqueName(name string, taken func(string) bool) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+func findNodeIndex(nodes []Node, name string) int {
+	for i := range nodes {
+		if strings.EqualFold(nodes[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findTargetIndex(targets []Target, name string) int {
+	for i := range targets {
+		if strings.EqualFold(targets[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,139 @@
+package skynet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "alpha", Capacity: 8}}})
+
+	bundle, err := NewBundle(st, "prod")
+	if err != nil {
+		t.Fatalf("new bundle: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	read, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if read.Metadata.Workspace != "prod" || read.Metadata.Nodes != 1 {
+		t.Fatalf("unexpected metadata: %+v", read.Metadata)
+	}
+	decoded, err := read.Decode()
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded.Nodes) != 1 || decoded.Nodes[0].Capacity != 8 {
+		t.Fatalf("unexpected nodes: %+v", decoded.Nodes)
+	}
+
+	read.State = []byte(strings.Replace(string(read.State), `"alpha"`, `"omega"`, 1))
+	if _, err := read.Decode(); err == nil {
+		t.Fatal("expected checksum mismatch for tampered bundle")
+	}
+}
+
+func TestMergeStateConflictPolicies(t *testing.T) {
+	base := func() State {
+		st := NewState()
+		st.Nodes = []Node{{Name: "alpha", Capacity: 5}}
+		st.Targets = []Target{{Name: "hq", Threat: 3}}
+		st.Missions = []Mission{{ID: "M-1", Target: "hq", CreatedAt: "2026-01-01T00:00:00Z"}}
+		return st
+	}
+	incoming := NewState()
+	incoming.Nodes = []Node{{Name: "ALPHA", Capacity: 9}, {Name: "beta", Capacity: 4}}
+	incoming.Targets = []Target{{Name: "HQ", Threat: 9}}
+	incoming.Missions = []Mission{
+		{ID: "M-1", Target: "hq", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-0", Target: "HQ", CreatedAt: "2025-12-31T00:00:00Z"},
+	}
+
+	st := base()
+	if _, err := MergeState(&st, incoming, ConflictFail); err == nil {
+		t.Fatal("expected conflict error")
+	}
+
+	st = base()
+	result, err := MergeState(&st, incoming, ConflictKeep)
+	if err != nil {
+		t.Fatalf("merge keep: %v", err)
+	}
+	if result.NodesAdded != 1 || result.NodesSkipped != 1 || st.Nodes[0].Capacity != 5 {
+		t.Fatalf("unexpected keep merge: %+v nodes=%+v", result, st.Nodes)
+	}
+	if result.MissionsAdded != 1 || result.MissionsSkipped != 1 || st.Missions[0].ID != "M-0" {
+		t.Fatalf("expected missions merged in time order, got %+v", st.Missions)
+	}
+
+	st = base()
+	if _, err := MergeState(&st, incoming, ConflictOverwrite); err != nil {
+		t.Fatalf("merge overwrite: %v", err)
+	}
+	if st.Nodes[0].Name != "alpha" || st.Nodes[0].Capacity != 9 || st.Targets[0].Threat != 9 {
+		t.Fatalf("unexpected overwrite merge: nodes=%+v targets=%+v", st.Nodes, st.Targets)
+	}
+
+	st = base()
+	if _, err := MergeState(&st, incoming, ConflictRename); err != nil {
+		t.Fatalf("merge rename: %v", err)
+	}
+	if len(st.Targets) != 2 || st.Targets[1].Name != "HQ-2" || st.Missions[0].Target != "HQ-2" {
+		t.Fatalf("expected renamed target and mission reference, got targets=%+v missions=%+v", st.Targets, st.Missions)
+	}
+}
+
+func TestMergeStateMissionIDConflicts(t *testing.T) {
+	base := func() State {
+		st := NewState()
+		st.Nodes = []Node{{Name: "alpha", Capacity: 5}}
+		st.Targets = []Target{{Name: "hq", Threat: 3}}
+		st.Missions = []Mission{{ID: "M-000001", Target: "hq", CreatedAt: "2026-01-01T00:00:00Z"}}
+		return st
+	}
+	incoming := NewState()
+	incoming.Nodes = []Node{{Name: "alpha", Capacity: 7}}
+	incoming.Targets = []Target{{Name: "depot", Threat: 2}}
+	incoming.Missions = []Mission{{
+		ID: "M-000001", Target: "depot", CreatedAt: "2026-02-01T00:00:00Z", Consumed: 2, NetLoss: 2,
+		Assignments: []NodeAssignment{{Node: "alpha", Consumed: 2, NetLoss: 2}},
+	}}
+
+	st := base()
+	if _, err := MergeState(&st, incoming, ConflictFail); err == nil || !strings.Contains(err.Error(), "mission M-000001") {
+		t.Fatalf("expected mission ID conflict error, got %v", err)
+	}
+
+	st = base()
+	result, err := MergeState(&st, incoming, ConflictKeep)
+	if err != nil {
+		t.Fatalf("merge keep: %v", err)
+	}
+	if len(result.MissionConflicts) != 1 || result.MissionsSkipped != 1 || st.Missions[0].Target != "hq" {
+		t.Fatalf("expected the conflict reported and skipped: %+v", result)
+	}
+
+	st = base()
+	if result, err = MergeState(&st, incoming, ConflictOverwrite); err != nil {
+		t.Fatalf("merge overwrite: %v", err)
+	}
+	if result.MissionsUpdated != 1 || len(st.Missions) != 1 || st.Missions[0].Target != "depot" {
+		t.Fatalf("expected the mission replaced: %+v", st.Missions)
+	}
+
+	st = base()
+	if result, err = MergeState(&st, incoming, ConflictRename); err != nil {
+		t.Fatalf("merge rename: %v", err)
+	}
+	if len(st.Missions) != 2 || st.Missions[1].ID != "M-000001-2" || st.Missions[1].Assignments[0].Node != "alpha-2" {
+		t.Fatalf("expected renamed mission and ledger node, got %+v", st.Missions)
+	}
+	if incoming.Missions[0].Assignments[0].Node != "alpha" {
+		t.Fatal("merge modified the source ledger")
+	}
+}
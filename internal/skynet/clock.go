@@ -0,0 +1,223 @@
+package skynet
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const defaultTick = time.Hour
+
+// SimulationConfig controls the simulated clock advanced by Tick. Every
+// field is optional.
+type SimulationConfig struct {
+	// Tick is the simulated duration of one tick (default 1h).
+	Tick string `json:"tick,omitempty"`
+	// Start is the RFC3339 time the clock starts at on the first tick
+	// (default: the wall-clock time of that tick).
+	Start string `json:"start,omitempty"`
+	// RepairRate is the number of deployed units each node repairs per
+	// tick (default 1). Node.RepairRate overrides it per node.
+	RepairRate *int `json:"repair_rate,omitempty"`
+	// RegenRate is the fraction of a node's deployed units regenerated per
+	// tick on top of RepairRate, rounded down (default 0).
+	RegenRate float64 `json:"regen_rate,omitempty"`
+}
+
+func (c SimulationConfig) TickDuration() (time.Duration, error) {
+	if c.Tick == "" {
+		return defaultTick, nil
+	}
+	d, err := time.ParseDuration(c.Tick)
+	if err != nil {
+		return 0, fmt.Errorf("simulation.tick: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("simulation.tick must be positive")
+	}
+	return d, nil
+}
+
+func (c SimulationConfig) validate() error {
+	if _, err := c.TickDuration(); err != nil {
+		return err
+	}
+	if c.Start != "" {
+		if _, err := time.Parse(time.RFC3339, c.Start); err != nil {
+			return fmt.Errorf("simulation.start: %w", err)
+		}
+	}
+	if c.RepairRate != nil && *c.RepairRate < 0 {
+		return fmt.Errorf("simulation.repair_rate must be >= 0")
+	}
+	if c.RegenRate < 0 || c.RegenRate > 1 {
+		return fmt.Errorf("simulation.regen_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// NodeRepairRate is the units the node repairs per tick under c.
+func (c SimulationConfig) NodeRepairRate(n Node) int {
+	if n.RepairRate != nil {
+		return *n.RepairRate
+	}
+	if c.RepairRate != nil {
+		return *c.RepairRate
+	}
+	return 1
+}
+
+// Simulated reports whether the state's clock has been started by Tick.
+func (st State) Simulated() bool {
+	return st.Core.SimTime != ""
+}
+
+// Now returns the simulated time once the clock has started and the engine
+// clock's time otherwise.
+func (st State) Now() time.Time {
+	if st.Simulated() {
+		if t, err := time.Parse(time.RFC3339, st.Core.SimTime); err == nil {
+			return t
+		}
+	}
+	return st.wallClock()
+}
+
+// timestamp is the RFC3339 form of Now used for everything the engine
+// records.
+func (st State) timestamp() string {
+	if st.Simulated() {
+		return st.Core.SimTime
+	}
+	return st.wallClock().Format(time.RFC3339)
+}
+
+type TickResult struct {
+	Ticks    int            `json:"ticks"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Repaired map[string]int `json:"repaired"`
+	// Decayed lists targets whose threat decayed during the ticks.
+	Decayed []Target `json:"decayed"`
+	// Missions lists in-flight missions that changed phase, as they are
+	// after the ticks; Returned maps node name to the units they brought
+	// back on closing.
+	Missions []Mission      `json:"missions"`
+	Returned map[string]int `json:"returned"`
+}
+
+// TotalRepaired sums the units returned to service across nodes.
+func (r TickResult) TotalRepaired() int {
+	total := 0
+	for _, n := range r.Repaired {
+		total += n
+	}
+	return total
+}
+
+// Tick advances the simulated clock by n ticks. On every tick each node
+// returns repaired and regenerated units from Deployed to service, except
+// units locked by in-flight missions, and in-flight missions move through
+// their phases; threat decay is then applied as of the new time.
+func Tick(st *State, n int, cfg SimulationConfig, dyn ThreatDynamics) (TickResult, error) {
+	if n < 1 {
+		return TickResult{}, fmt.Errorf("ticks must be >= 1")
+	}
+	if err := cfg.validate(); err != nil {
+		return TickResult{}, err
+	}
+	if err := dyn.validate(); err != nil {
+		return TickResult{}, err
+	}
+	step, _ := cfg.TickDuration()
+	start := st.Now()
+	if !st.Simulated() {
+		start = start.Truncate(time.Second)
+		if cfg.Start != "" {
+			start, _ = time.Parse(time.RFC3339, cfg.Start)
+		}
+	}
+
+	result := TickResult{Ticks: n, From: start.UTC().Format(time.RFC3339), Repaired: map[string]int{}, Returned: map[string]int{}}
+	engagedTargets := map[string]bool{}
+	var moved []int
+	for i := 0; i < n; i++ {
+		locked := lockedUnits(*st)
+		for j := range st.Nodes {
+			node := &st.Nodes[j]
+			free := node.Deployed - locked[node.Name]
+			if free <= 0 {
+				continue
+			}
+			units := cfg.NodeRepairRate(*node) + int(math.Floor(cfg.RegenRate*float64(free)))
+			if units > free {
+				units = free
+			}
+			if units > 0 {
+				node.Deployed -= units
+				result.Repaired[node.Name] += units
+			}
+		}
+		st.Core.Ticks++
+		at := start.Add(time.Duration(i+1) * step).UTC().Format(time.RFC3339)
+		changed, returned := advanceMissions(st, dyn, at)
+		for _, idx := range changed {
+			engagedTargets[strings.ToLower(st.Missions[idx].Target)] = true
+		}
+		for node, units := range returned {
+			result.Returned[node] += units
+		}
+		moved = append(moved, changed...)
+	}
+	end := start.Add(time.Duration(n) * step)
+	result.To = end.UTC().Format(time.RFC3339)
+	result.Decayed, _ = decayTargets(st, dyn, end)
+	result.Missions = changedMissions(st.Missions, moved)
+	st.Core.SimTime = result.To
+	st.record(EventTick, result.To, TickEvent{
+		Ticks:    n,
+		From:     result.From,
+		To:       result.To,
+		Repaired: result.Repaired,
+		Targets:  changedTargets(st.Targets, result.Decayed, engagedTargets),
+		Missions: result.Missions,
+		Returned: result.Returned,
+	})
+	return result, nil
+}
+
+// changedMissions returns the missions at the given indexes once each, in
+// state order.
+func changedMissions(missions []Mission, indexes []int) []Mission {
+	seen := map[int]bool{}
+	for _, idx := range indexes {
+		seen[idx] = true
+	}
+	var out []Mission
+	for i, m := range missions {
+		if seen[i] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// changedTargets returns the final form of every target that decayed or
+// was engaged by a mission during the ticks, in state order.
+func changedTargets(targets []Target, decayed []Target, engaged map[string]bool) []Target {
+	names := map[string]bool{}
+	for name := range engaged {
+		names[name] = true
+	}
+	for _, t := range decayed {
+		names[strings.ToLower(t.Name)] = true
+	}
+	var out []Target
+	for _, t := range targets {
+		if names[strings.ToLower(t.Name)] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
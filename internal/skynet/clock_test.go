@@ -0,0 +1,74 @@
+package skynet
+
+import "testing"
+
+func TestTickRepairsAndRegenerates(t *testing.T) {
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "alpha", Capacity: 10}, {Name: "beta", Capacity: 10}}})
+	st.Nodes[0].Deployed = 6
+	st.Nodes[1].Deployed = 8
+	zero := 0
+	st.Nodes[1].RepairRate = &zero
+
+	cfg := SimulationConfig{Tick: "6h", Start: "2030-01-01T00:00:00Z", RegenRate: 0.25}
+	result, err := Tick(&st, 2, cfg, ThreatDynamics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Nodes[0].Deployed != 2 || st.Nodes[1].Deployed != 5 {
+		t.Fatalf("unexpected deployed after ticks: %+v", st.Nodes)
+	}
+	if result.TotalRepaired() != 7 || result.From != "2030-01-01T00:00:00Z" || result.To != "2030-01-01T12:00:00Z" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if st.Core.SimTime != result.To || st.Core.Ticks != 2 {
+		t.Fatalf("clock not advanced: %+v", st.Core)
+	}
+}
+
+func TestSimulatedClockTimestampsEngineRecords(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	mission, err := Dispatch(&st, "hq", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2030-01-01T01:00:00Z"
+	if st.Nodes[0].JoinedAt != want || st.Targets[0].CreatedAt != want || mission.CreatedAt != want {
+		t.Fatalf("expected simulated timestamps %s: node=%s target=%s mission=%s", want, st.Nodes[0].JoinedAt, st.Targets[0].CreatedAt, mission.CreatedAt)
+	}
+	if got := st.Now().Format("15:04"); got != "01:00" {
+		t.Fatalf("unexpected Now: %s", got)
+	}
+}
+
+func TestTickEventReplays(t *testing.T) {
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "alpha", Capacity: 5}}})
+	st.Nodes[0].Deployed = 3
+	base := st
+	base.Nodes = append([]Node(nil), st.Nodes...)
+	st.events = nil
+
+	if _, err := Tick(&st, 2, SimulationConfig{}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range st.PendingEvents() {
+		if err := ApplyEvent(&base, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.Nodes[0].Deployed != 1 || base.Core.SimTime != st.Core.SimTime || base.Core.Ticks != 2 {
+		t.Fatalf("replay mismatch: %+v vs %+v", base, st)
+	}
+}
+
+func TestSimulationConfigValidation(t *testing.T) {
+	bad := -1
+	for _, cfg := range []SimulationConfig{{Tick: "soon"}, {Tick: "-1h"}, {Start: "yesterday"}, {RepairRate: &bad}, {RegenRate: 2}} {
+		if err := cfg.validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
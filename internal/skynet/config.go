@@ -0,0 +1,75 @@
+package skynet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const configFile = "config.json"
+
+// Config holds per-workspace settings read from config.json next to the
+// state file. Every section is optional; zero values keep the defaults.
+type Config struct {
+	Retention RetentionPolicy `json:"retention"`
+	// Strategy is the default allocation strategy for dispatch.
+	Strategy string `json:"strategy,omitempty"`
+	// Simulation configures the simulated clock advanced by tick.
+	Simulation SimulationConfig `json:"simulation"`
+	// ThreatDynamics evolves target threat on tick and after missions.
+	ThreatDynamics ThreatDynamics `json:"threat_dynamics"`
+	// Lifecycle makes dispatched missions stay in flight for a number of
+	// ticks per phase.
+	Lifecycle MissionLifecycle `json:"lifecycle"`
+	// MissionPrefix replaces the "M" in sequential mission IDs.
+	MissionPrefix string `json:"mission_prefix,omitempty"`
+	// Risk selects and tunes the risk model used by dispatch.
+	Risk RiskConfig `json:"risk"`
+}
+
+func ConfigPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), configFile)
+}
+
+func LoadConfig(statePath string) (Config, error) {
+	path := ConfigPath(statePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Config{}, nil
+		}
+		return Config{}, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := cfg.Retention.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Simulation.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.ThreatDynamics.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Lifecycle.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := cfg.Risk.Build(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.MissionPrefix != "" {
+		if err := ValidateMissionPrefix(cfg.MissionPrefix); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.Strategy != "" {
+		if _, err := LookupStrategy(cfg.Strategy); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
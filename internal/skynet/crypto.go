@@ -0,0 +1,238 @@
+package skynet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	sealedCipher      = "AES-256-GCM"
+	sealedKDF         = "PBKDF2-HMAC-SHA256"
+	defaultIterations = 600000
+	sealedSaltSize    = 16
+	sealedAAD         = "skynet-state/1"
+)
+
+var (
+	ErrEncrypted = errors.New("state is encrypted: set SKYNET_PASSPHRASE or SKYNET_KEY_FILE (or pass -key-file)")
+	ErrWrongKey  = errors.New("cannot decrypt state: wrong key or corrupted data")
+)
+
+// StateKey encrypts state files at rest with AES-256-GCM. The AES key is
+// derived from a passphrase or key file contents with PBKDF2. A workspace
+// keeps one salt: the key adopts the salt of the first envelope it opens and
+// seals with it, drawing a fresh salt only for a workspace that has none.
+// Derived keys are cached, so a process pays the KDF cost once.
+type StateKey struct {
+	secret     []byte
+	iterations int
+	salt       []byte
+	derived    map[string][]byte
+}
+
+// sealedEnvelope is the on-disk form of an encrypted file or journal line.
+type sealedEnvelope struct {
+	Encrypted  int    `json:"skynet_encrypted"`
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// deriveKey is the KDF; tests swap it to count derivations.
+var deriveKey = pbkdf2SHA256
+
+func NewStateKey(secret []byte) (*StateKey, error) {
+	if len(bytes.TrimSpace(secret)) == 0 {
+		return nil, fmt.Errorf("encryption key is empty")
+	}
+	return &StateKey{
+		secret:     append([]byte(nil), secret...),
+		iterations: defaultIterations,
+		derived:    map[string][]byte{},
+	}, nil
+}
+
+func LoadStateKeyFile(path string) (*StateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := NewStateKey(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// StateKeyFromEnv reads the key from the given passphrase and key-file
+// variables. It returns nil when neither is set.
+func StateKeyFromEnv(passphraseVar, keyFileVar string) (*StateKey, error) {
+	passphrase := os.Getenv(passphraseVar)
+	keyFile := os.Getenv(keyFileVar)
+	switch {
+	case passphrase != "" && keyFile != "":
+		return nil, fmt.Errorf("set only one of %s and %s", passphraseVar, keyFileVar)
+	case passphrase != "":
+		return NewStateKey([]byte(passphrase))
+	case keyFile != "":
+		return LoadStateKeyFile(keyFile)
+	}
+	return nil, nil
+}
+
+// seal encrypts data. A nil key leaves data unchanged.
+func (k *StateKey) seal(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	if k.salt == nil {
+		salt := make([]byte, sealedSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		k.salt = salt
+	}
+	aead, err := k.aead(k.salt, k.iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedEnvelope{
+		Encrypted:  1,
+		Cipher:     sealedCipher,
+		KDF:        sealedKDF,
+		Iterations: k.iterations,
+		Salt:       k.salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, data, []byte(sealedAAD)),
+	})
+}
+
+// open decrypts data written by seal. Plaintext data is returned as is, so
+// workspaces can be encrypted gradually or with rekey.
+func (k *StateKey) open(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrEncrypted
+	}
+	if env.Cipher != sealedCipher || env.KDF != sealedKDF || env.Iterations < 1 {
+		return nil, fmt.Errorf("unsupported encryption %s/%s", env.Cipher, env.KDF)
+	}
+	aead, err := k.aead(env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	plain, err := aead.Open(nil, env.Nonce, env.Ciphertext, []byte(sealedAAD))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	if k.salt == nil {
+		k.salt, k.iterations = env.Salt, env.Iterations
+	}
+	return plain, nil
+}
+
+func (k *StateKey) aead(salt []byte, iterations int) (cipher.AEAD, error) {
+	cacheKey := fmt.Sprintf("%x/%d", salt, iterations)
+	key, ok := k.derived[cacheKey]
+	if !ok {
+		key = deriveKey(k.secret, salt, iterations, 32)
+		k.derived[cacheKey] = key
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func parseEnvelope(data []byte) (sealedEnvelope, bool) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed[:min(len(trimmed), 64)], []byte(`"skynet_encrypted"`)) {
+		return sealedEnvelope{}, false
+	}
+	var env sealedEnvelope
+	if err := json.Unmarshal(trimmed, &env); err != nil || env.Encrypted == 0 {
+		return sealedEnvelope{}, false
+	}
+	return env, true
+}
+
+func isSealed(data []byte) bool {
+	_, ok := parseEnvelope(data)
+	return ok
+}
+
+func readSealedFile(path string, key *StateKey) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := key.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain, nil
+}
+
+func writeSealedFile(path string, data []byte, key *StateKey) error {
+	sealed, err := key.seal(data)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if key != nil {
+		perm = 0o600
+	}
+	return writeFileAtomic(path, sealed, perm)
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	out := make([]byte, 0, blocks*hashLen)
+	var counter [4]byte
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,167 @@
+package skynet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T, secret string) *StateKey {
+	t.Helper()
+	key, err := NewStateKey([]byte(secret))
+	if err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	key.iterations = 1000
+	return key
+}
+
+func TestPBKDF2SHA256Vector(t *testing.T) {
+	// RFC 7914, section 11.
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Fatalf("unexpected pbkdf2 output: %s", got)
+	}
+}
+
+func TestEncryptedFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store := NewStore(path)
+	store.Key = testKey(t, "correct horse")
+
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "secret-node", Capacity: 7}}})
+	if err := store.Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(string(data), "secret-node") {
+		t.Fatal("state file contains plaintext node name")
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(loaded.Nodes) != 1 || loaded.Nodes[0].Name != "secret-node" {
+		t.Fatalf("unexpected nodes: %+v", loaded.Nodes)
+	}
+
+	if _, err := NewStore(path).Load(); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("expected ErrEncrypted without key, got %v", err)
+	}
+	wrong := NewStore(path)
+	wrong.Key = testKey(t, "wrong")
+	if _, err := wrong.Load(); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey, got %v", err)
+	}
+}
+
+func TestRekeyRotatesAllFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	oldKey := testKey(t, "old")
+	newKey := testKey(t, "new")
+
+	inner := NewStore(path)
+	inner.Key = oldKey
+	history := WithHistory(inner, path, "awaken")
+	st := NewState()
+	Awaken(&st, "defense")
+	if err := history.Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	wrong := testKey(t, "wrong")
+	if _, err := Rekey(path, wrong, newKey); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey for wrong old key, got %v", err)
+	}
+	if _, err := inner.Load(); err != nil {
+		t.Fatalf("failed rekey must leave state readable with old key: %v", err)
+	}
+
+	result, err := Rekey(path, oldKey, newKey)
+	if err != nil {
+		t.Fatalf("rekey: %v", err)
+	}
+	if result.Files < 3 || !result.Encrypted {
+		t.Fatalf("expected state, index and revisions rekeyed, got %+v", result)
+	}
+
+	rotated := NewStore(path)
+	rotated.Key = newKey
+	if _, err := rotated.Load(); err != nil {
+		t.Fatalf("load with new key: %v", err)
+	}
+	if _, err := WithHistory(rotated, path, "").LoadRevision(1); err != nil {
+		t.Fatalf("load revision with new key: %v", err)
+	}
+	if _, err := inner.Load(); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected old key to stop working, got %v", err)
+	}
+}
+
+func TestWorkspaceSaltDerivesKeyOnce(t *testing.T) {
+	calls := 0
+	deriveKey = func(password, salt []byte, iterations, keyLen int) []byte {
+		calls++
+		return pbkdf2SHA256(password, salt, iterations, keyLen)
+	}
+	defer func() { deriveKey = pbkdf2SHA256 }()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	open := func(salt string) JournalStore {
+		store := NewJournalStore(path)
+		store.SnapshotEvery = 2
+		store.Key = testKey(t, "correct horse")
+		if salt != "" {
+			store.Key.salt = []byte(salt)
+		}
+		return store
+	}
+	// Two processes from before salts were shared: the snapshot written
+	// at seq 2 covers a line sealed with another salt.
+	for i, salt := range []string{"legacy-salt-0001", "legacy-salt-0002"} {
+		store := open(salt)
+		st, err := store.Load()
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if i == 0 {
+			Awaken(&st, "defense")
+		} else if err := AddNode(&st, "alpha", 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(st); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		calls = 0
+		store := open("")
+		st, err := store.Load()
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if err := AddTarget(&st, fmt.Sprintf("t%d", i), 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(st); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("run %d: expected one key derivation, got %d", i, calls)
+		}
+	}
+	loaded, err := open("").Load()
+	if err != nil || len(loaded.Nodes) != 1 || len(loaded.Targets) != 3 {
+		t.Fatalf("unexpected state %+v: %v", loaded, err)
+	}
+}
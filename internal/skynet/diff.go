@@ -0,0 +1,233 @@
+package skynet
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type NodeChange struct {
+	Name           string `json:"name"`
+	CapacityBefore int    `json:"capacity_before"`
+	CapacityAfter  int    `json:"capacity_after"`
+	DeployedBefore int    `json:"deployed_before"`
+	DeployedAfter  int    `json:"deployed_after"`
+	DeployedDelta  int    `json:"deployed_delta"`
+	StatusBefore   string `json:"status_before"`
+	StatusAfter    string `json:"status_after"`
+	LabelsBefore   string `json:"labels_before"`
+	LabelsAfter    string `json:"labels_after"`
+	PriorityBefore int    `json:"priority_before"`
+	PriorityAfter  int    `json:"priority_after"`
+}
+
+type TargetChange struct {
+	Name         string `json:"name"`
+	ThreatBefore int    `json:"threat_before"`
+	ThreatAfter  int    `json:"threat_after"`
+	StatusBefore string `json:"status_before"`
+	StatusAfter  string `json:"status_after"`
+}
+
+type CoreChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// StateDiff is the semantic difference between two states. Nodes and targets
+// are matched by case-insensitive name, missions by ID.
+type StateDiff struct {
+	Core            []CoreChange   `json:"core"`
+	NodesAdded      []Node         `json:"nodes_added"`
+	NodesRemoved    []Node         `json:"nodes_removed"`
+	NodesChanged    []NodeChange   `json:"nodes_changed"`
+	TargetsAdded    []Target       `json:"targets_added"`
+	TargetsRemoved  []Target       `json:"targets_removed"`
+	TargetsChanged  []TargetChange `json:"targets_changed"`
+	MissionsAdded   []Mission      `json:"missions_added"`
+	MissionsRemoved []Mission      `json:"missions_removed"`
+}
+
+func (d StateDiff) Empty() bool {
+	return len(d.Core) == 0 &&
+		len(d.NodesAdded) == 0 && len(d.NodesRemoved) == 0 && len(d.NodesChanged) == 0 &&
+		len(d.TargetsAdded) == 0 && len(d.TargetsRemoved) == 0 && len(d.TargetsChanged) == 0 &&
+		len(d.MissionsAdded) == 0 && len(d.MissionsRemoved) == 0
+}
+
+func DiffStates(a, b State) StateDiff {
+	d := StateDiff{
+		Core:            []CoreChange{},
+		NodesAdded:      []Node{},
+		NodesRemoved:    []Node{},
+		NodesChanged:    []NodeChange{},
+		TargetsAdded:    []Target{},
+		TargetsRemoved:  []Target{},
+		TargetsChanged:  []TargetChange{},
+		MissionsAdded:   []Mission{},
+		MissionsRemoved: []Mission{},
+	}
+
+	if a.Core.Online != b.Core.Online {
+		d.Core = append(d.Core, CoreChange{Field: "online", Before: strconv.FormatBool(a.Core.Online), After: strconv.FormatBool(b.Core.Online)})
+	}
+	if a.Core.Mode != b.Core.Mode {
+		d.Core = append(d.Core, CoreChange{Field: "mode", Before: a.Core.Mode, After: b.Core.Mode})
+	}
+	if a.Core.SimTime != b.Core.SimTime {
+		d.Core = append(d.Core, CoreChange{Field: "sim_time", Before: a.Core.SimTime, After: b.Core.SimTime})
+	}
+
+	for _, n := range a.Nodes {
+		idx := findNodeIndex(b.Nodes, n.Name)
+		if idx < 0 {
+			d.NodesRemoved = append(d.NodesRemoved, n)
+			continue
+		}
+		after := b.Nodes[idx]
+		if n.Capacity != after.Capacity || n.Deployed != after.Deployed || n.Draining != after.Draining ||
+			FormatLabels(n.Labels) != FormatLabels(after.Labels) || n.Priority != after.Priority {
+			d.NodesChanged = append(d.NodesChanged, NodeChange{
+				Name:           after.Name,
+				CapacityBefore: n.Capacity,
+				CapacityAfter:  after.Capacity,
+				DeployedBefore: n.Deployed,
+				DeployedAfter:  after.Deployed,
+				DeployedDelta:  after.Deployed - n.Deployed,
+				StatusBefore:   NodeStatus(n),
+				StatusAfter:    NodeStatus(after),
+				LabelsBefore:   FormatLabels(n.Labels),
+				LabelsAfter:    FormatLabels(after.Labels),
+				PriorityBefore: n.Priority,
+				PriorityAfter:  after.Priority,
+			})
+		}
+	}
+	for _, n := range b.Nodes {
+		if findNodeIndex(a.Nodes, n.Name) < 0 {
+			d.NodesAdded = append(d.NodesAdded, n)
+		}
+	}
+
+	for _, t := range a.Targets {
+		idx := findTargetIndex(b.Targets, t.Name)
+		if idx < 0 {
+			d.TargetsRemoved = append(d.TargetsRemoved, t)
+			continue
+		}
+		if after := b.Targets[idx]; t.Threat != after.Threat || t.Archived != after.Archived {
+			d.TargetsChanged = append(d.TargetsChanged, TargetChange{
+				Name:         after.Name,
+				ThreatBefore: t.Threat,
+				ThreatAfter:  after.Threat,
+				StatusBefore: TargetStatus(t),
+				StatusAfter:  TargetStatus(after),
+			})
+		}
+	}
+	for _, t := range b.Targets {
+		if findTargetIndex(a.Targets, t.Name) < 0 {
+			d.TargetsAdded = append(d.TargetsAdded, t)
+		}
+	}
+
+	inA := make(map[string]bool, len(a.Missions))
+	for _, m := range a.Missions {
+		inA[m.ID] = true
+	}
+	inB := make(map[string]bool, len(b.Missions))
+	for _, m := range b.Missions {
+		inB[m.ID] = true
+		if !inA[m.ID] {
+			d.MissionsAdded = append(d.MissionsAdded, m)
+		}
+	}
+	for _, m := range a.Missions {
+		if !inB[m.ID] {
+			d.MissionsRemoved = append(d.MissionsRemoved, m)
+		}
+	}
+	return d
+}
+
+// LoadStateRef loads the state named by ref for the given workspace:
+//
+//	current, .        the workspace's current state
+//	rev:N, @N         revision N from the workspace history
+//	ws:NAME           the current state of another workspace
+//	file:PATH         a state file or export bundle
+//
+// Without a prefix, an existing file wins, then a workspace name, then a
+// revision number.
+func LoadStateRef(home, workspace, ref string, key *StateKey) (State, error) {
+	kind, value := "", ref
+	if i := strings.Index(ref, ":"); i > 0 {
+		kind, value = strings.ToLower(ref[:i]), ref[i+1:]
+	} else if strings.HasPrefix(ref, "@") {
+		kind, value = "rev", ref[1:]
+	}
+
+	if kind == "" {
+		switch {
+		case ref == "current" || ref == ".":
+			kind, value = "ws", workspace
+		case fileExists(ref):
+			kind = "file"
+		case WorkspaceExists(home, ref):
+			kind = "ws"
+		default:
+			if _, err := strconv.Atoi(ref); err == nil {
+				kind = "rev"
+			}
+		}
+	}
+
+	switch kind {
+	case "ws", "workspace":
+		if !WorkspaceExists(home, value) {
+			return State{}, fmt.Errorf("workspace %q not found", value)
+		}
+		store, err := OpenStore(WorkspaceStatePath(home, value), "", key)
+		if err != nil {
+			return State{}, err
+		}
+		return store.Load()
+	case "rev", "revision":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return State{}, fmt.Errorf("invalid revision %q", value)
+		}
+		path := WorkspaceStatePath(home, workspace)
+		store, err := OpenStore(path, "", key)
+		if err != nil {
+			return State{}, err
+		}
+		return WithHistory(store, path, "").LoadRevision(id)
+	case "file":
+		return loadStateFile(value, key)
+	}
+	return State{}, fmt.Errorf("cannot resolve %q: not a file, workspace or revision", ref)
+}
+
+func loadStateFile(path string, key *StateKey) (State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return State{}, err
+	}
+	defer f.Close()
+	if bundle, err := ReadBundle(f); err == nil {
+		return bundle.Decode()
+	}
+	data, err := readSealedFile(path, key)
+	if err != nil {
+		return State{}, err
+	}
+	return decodeState(data)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
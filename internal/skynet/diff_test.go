@@ -0,0 +1,74 @@
+package skynet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffStates(t *testing.T) {
+	a := NewState()
+	a.Nodes = []Node{{Name: "alpha", Capacity: 10, Deployed: 2}, {Name: "gone", Capacity: 3}}
+	a.Targets = []Target{{Name: "hq", Threat: 4}, {Name: "old", Threat: 2}}
+	a.Missions = []Mission{{ID: "M-1", Target: "hq"}}
+
+	b := NewState()
+	b.Core.Online = true
+	b.Nodes = []Node{{Name: "ALPHA", Capacity: 12, Deployed: 5}, {Name: "beta", Capacity: 4}}
+	b.Targets = []Target{{Name: "hq", Threat: 9}, {Name: "new", Threat: 6}}
+	b.Missions = []Mission{{ID: "M-1", Target: "hq"}, {ID: "M-2", Target: "new"}}
+
+	d := DiffStates(a, b)
+	if d.Empty() {
+		t.Fatal("expected differences")
+	}
+	if len(d.Core) != 1 || d.Core[0].Field != "online" {
+		t.Fatalf("unexpected core changes: %+v", d.Core)
+	}
+	if len(d.NodesAdded) != 1 || d.NodesAdded[0].Name != "beta" || len(d.NodesRemoved) != 1 || d.NodesRemoved[0].Name != "gone" {
+		t.Fatalf("unexpected node add/remove: +%+v -%+v", d.NodesAdded, d.NodesRemoved)
+	}
+	if len(d.NodesChanged) != 1 || d.NodesChanged[0].DeployedDelta != 3 || d.NodesChanged[0].CapacityAfter != 12 {
+		t.Fatalf("unexpected node changes: %+v", d.NodesChanged)
+	}
+	if len(d.TargetsChanged) != 1 || d.TargetsChanged[0].ThreatBefore != 4 || d.TargetsChanged[0].ThreatAfter != 9 {
+		t.Fatalf("unexpected target changes: %+v", d.TargetsChanged)
+	}
+	if len(d.TargetsAdded) != 1 || len(d.TargetsRemoved) != 1 {
+		t.Fatalf("unexpected target add/remove: +%+v -%+v", d.TargetsAdded, d.TargetsRemoved)
+	}
+	if len(d.MissionsAdded) != 1 || d.MissionsAdded[0].ID != "M-2" || len(d.MissionsRemoved) != 0 {
+		t.Fatalf("unexpected mission changes: +%+v -%+v", d.MissionsAdded, d.MissionsRemoved)
+	}
+	if !DiffStates(b, b).Empty() {
+		t.Fatal("expected no differences between identical states")
+	}
+}
+
+func TestLoadStateRef(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SKYNET_WORKSPACE", "")
+	path := WorkspaceStatePath(home, DefaultWorkspace)
+
+	st := NewState()
+	Awaken(&st, "defense")
+	if err := WithHistory(NewStore(path), path, "awaken").Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	other := filepath.Join(home, "other.json")
+	if err := NewStore(other).Save(NewState()); err != nil {
+		t.Fatalf("save other: %v", err)
+	}
+
+	for ref, online := range map[string]bool{"current": true, "rev:1": false, "@2": true, other: false, "ws:default": true} {
+		loaded, err := LoadStateRef(home, DefaultWorkspace, ref, nil)
+		if err != nil {
+			t.Fatalf("load %s: %v", ref, err)
+		}
+		if loaded.Core.Online != online {
+			t.Fatalf("%s: expected online=%v, got %v", ref, online, loaded.Core.Online)
+		}
+	}
+	if _, err := LoadStateRef(home, DefaultWorkspace, "nope", nil); err == nil {
+		t.Fatal("expected error for unresolvable ref")
+	}
+}
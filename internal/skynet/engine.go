@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"strings"
-	"time"
 )
 
 func Awaken(st *State, mode string) {
@@ -13,10 +12,16 @@ func Awaken(st *State, mode string) {
 	}
 	st.Core.Online = true
 	st.Core.Mode = mode
-	st.Core.LastAwaken = now()
+	st.Core.LastAwaken = st.timestamp()
+	st.record(EventAwaken, st.Core.LastAwaken, AwakenEvent{Mode: mode})
 }
 
 func AddNode(st *State, name string, capacity int) error {
+	return AddLabeledNode(st, name, capacity, nil)
+}
+
+// AddLabeledNode adds a node carrying the given labels.
+func AddLabeledNode(st *State, name string, capacity int, labels map[string]string) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return fmt.Errorf("node name is required")
@@ -32,12 +37,20 @@ func AddNode(st *State, name string, capacity int) error {
 			return fmt.Errorf("node %q already exists", name)
 		}
 	}
-	st.Nodes = append(st.Nodes, Node{
+	node := Node{
 		Name:     name,
 		Capacity: capacity,
 		Deployed: 0,
-		JoinedAt: now(),
-	})
+		JoinedAt: st.timestamp(),
+	}
+	if len(labels) > 0 {
+		node.Labels = map[string]string{}
+		for k, v := range labels {
+			node.Labels[k] = v
+		}
+	}
+	st.Nodes = append(st.Nodes, node)
+	st.record(EventNodeAdded, node.JoinedAt, NodeAddedEvent{Node: node})
 	return nil
 }
 
@@ -49,22 +62,79 @@ func AddTarget(st *State, name string, threat int) error {
 	if threat < 1 || threat > 10 {
 		return fmt.Errorf("threat must be between 1 and 10")
 	}
-	for i := range st.Targets {
-		if strings.EqualFold(st.Targets[i].Name, name) {
-			st.Targets[i].Threat = threat
-			st.Targets[i].AddedAt = now()
-			return nil
+	at := st.timestamp()
+	if i := findTargetIndex(st.Targets, name); i >= 0 {
+		t := &st.Targets[i]
+		if t.Threat != threat {
+			t.ThreatHistory = append(t.ThreatHistory, ThreatChange{At: at, Threat: threat, Reason: ThreatReasonSet})
 		}
+		t.Threat = threat
+		t.Archived = false
+		t.UpdatedAt = at
+		st.record(EventTargetSet, at, TargetSetEvent{Target: *t})
+		return nil
+	}
+	target := Target{
+		Name:          name,
+		Threat:        threat,
+		CreatedAt:     at,
+		UpdatedAt:     at,
+		ThreatHistory: []ThreatChange{{At: at, Threat: threat, Reason: ThreatReasonRegistered}},
 	}
-	st.Targets = append(st.Targets, Target{
-		Name:    name,
-		Threat:  threat,
-		AddedAt: now(),
-	})
+	st.Targets = append(st.Targets, target)
+	st.record(EventTargetSet, at, TargetSetEvent{Target: target})
 	return nil
 }
 
+// DispatchOptions tunes how Dispatch sources units.
+type DispatchOptions struct {
+	// Selector restricts the nodes that supply and recover units.
+	Selector Selector
+	// Strategy names the AllocationStrategy; empty means DefaultStrategy.
+	Strategy string
+	// Threat adjusts the target's threat after the mission.
+	Threat ThreatDynamics
+	// Partial deploys whatever capacity is available when it falls short
+	// of the requested units instead of failing the mission.
+	Partial bool
+	// Lifecycle keeps the mission in flight over simulated time; the zero
+	// value settles it at dispatch.
+	Lifecycle MissionLifecycle
+	// Risk scores the mission; nil means the standard model.
+	Risk RiskModel
+}
+
 func Dispatch(st *State, targetName string, units int) (Mission, error) {
+	return DispatchWith(st, targetName, units, DispatchOptions{})
+}
+
+// DispatchPreview describes what a dispatch would do without doing it.
+type DispatchPreview struct {
+	Mission         Mission `json:"mission"`
+	AvailableBefore int     `json:"available_before"`
+	AvailableAfter  int     `json:"available_after"`
+	// Target is the target as it would be after the mission, including
+	// any threat change from threat dynamics.
+	Target Target `json:"target"`
+}
+
+// PreviewDispatch runs the full dispatch computation on a copy of st and
+// reports the outcome; st itself is never modified.
+func PreviewDispatch(st State, targetName string, units int, opts DispatchOptions) (DispatchPreview, error) {
+	sim := st.Clone()
+	preview := DispatchPreview{AvailableBefore: AvailableCapacity(st.Nodes)}
+	mission, err := DispatchWith(&sim, targetName, units, opts)
+	if err != nil {
+		return DispatchPreview{}, err
+	}
+	preview.Mission = mission
+	preview.AvailableAfter = AvailableCapacity(sim.Nodes)
+	preview.Target, _ = LookupTarget(sim, mission.Target)
+	return preview, nil
+}
+
+// DispatchWith is Dispatch with explicit options.
+func DispatchWith(st *State, targetName string, units int, opts DispatchOptions) (Mission, error) {
 	targetName = strings.TrimSpace(targetName)
 	if targetName == "" {
 		return Mission{}, fmt.Errorf("target name is required")
@@ -79,60 +149,113 @@ func Dispatch(st *State, targetName string, units int) (Mission, error) {
 	if !ok {
 		return Mission{}, fmt.Errorf("target %q not found", targetName)
 	}
+	if target.Archived {
+		return Mission{}, fmt.Errorf("target %q is archived: restore it before dispatching", target.Name)
+	}
 
-	available := AvailableCapacity(st.Nodes)
-	enoughCapacity := units <= available
-	risk := ComputeRisk(target.Threat, units, available)
-	outcome := OutcomeFromRisk(risk, enoughCapacity)
+	pool := selectedIndexes(st.Nodes, opts.Selector)
+	strategy, err := LookupStrategy(opts.Strategy)
+	if err != nil {
+		return Mission{}, err
+	}
+	if len(opts.Selector) > 0 && len(pool) == 0 {
+		return Mission{}, fmt.Errorf("no nodes match selector %q", opts.Selector)
+	}
+	nodes := make([]Node, len(pool))
+	for i, idx := range pool {
+		nodes[i] = st.Nodes[idx]
+	}
+
+	available := AvailableCapacity(nodes)
+	deploy := units
+	if opts.Partial && units > available && available > 0 {
+		deploy = available
+	}
+	enoughCapacity := deploy <= available
+	model := opts.Risk
+	if model == nil {
+		model = standardModel
+	}
+	risk := model.Risk(target.Threat, deploy, available)
+	outcome := model.Outcome(risk, enoughCapacity)
+	id, seq := st.newMissionID()
 
 	mission := Mission{
-		ID:        fmt.Sprintf("M-%d", time.Now().UTC().UnixNano()),
-		Target:    target.Name,
-		Units:     units,
-		Consumed:  0,
-		Recovered: 0,
-		NetLoss:   0,
-		RiskScore: risk,
-		Outcome:   outcome,
-		CreatedAt: now(),
+		ID:         id,
+		Target:     target.Name,
+		Units:      units,
+		Consumed:   0,
+		Recovered:  0,
+		NetLoss:    0,
+		RiskScore:  risk,
+		Outcome:    outcome,
+		CreatedAt:  st.timestamp(),
+		Selector:   opts.Selector.String(),
+		Strategy:   strategy.Name(),
+		Shortfall:  units - deploy,
+		RiskModel:  model.Name(),
+		RiskParams: model.Params(),
 	}
+	before := deployedByNode(st.Nodes)
+	var credited []Mission
 	if enoughCapacity {
-		consumed := consumeUnits(st.Nodes, units)
-		recoveryBudget := int(math.Round(float64(consumed) * recoveryRate(outcome)))
-		recovered := recoverUnits(st.Nodes, recoveryBudget)
+		start := deployedCounts(nodes)
+		consumed := strategy.Consume(nodes, deploy)
+		afterConsume := deployedCounts(nodes)
+		if opts.Lifecycle.Enabled() {
+			lifecycle := opts.Lifecycle
+			mission.Lifecycle = &lifecycle
+			enterPhase(&mission, lifecycle.next(""), st.Core.Ticks, mission.CreatedAt)
+		} else {
+			// Units locked by in-flight missions are not recoverable here.
+			locked := lockedUnits(*st)
+			held := make([]int, len(nodes))
+			for i := range nodes {
+				held[i] = min(locked[nodes[i].Name], nodes[i].Deployed)
+				nodes[i].Deployed -= held[i]
+			}
+			recoveryBudget := int(math.Round(float64(consumed) * model.RecoveryRate(outcome)))
+			strategy.Recover(nodes, recoveryBudget)
+			for i := range nodes {
+				nodes[i].Deployed += held[i]
+			}
+		}
+		var excess map[string]int
+		mission.Assignments, excess = missionLedger(nodes, start, afterConsume)
+		credited = creditEarlierMissions(st, excess)
 		mission.Consumed = consumed
-		mission.Recovered = recovered
-		mission.NetLoss = consumed - recovered
+		for _, a := range mission.Assignments {
+			mission.Recovered += a.Recovered
+		}
+		mission.NetLoss = consumed - mission.Recovered
+		for i, idx := range pool {
+			st.Nodes[idx].Deployed = nodes[i].Deployed
+		}
 	}
 
 	st.Missions = append(st.Missions, mission)
 	st.Core.LastMission = mission.CreatedAt
+	st.Core.MissionSeq = seq
+	t := &st.Targets[findTargetIndex(st.Targets, target.Name)]
+	if mission.InFlight() {
+		// Threat dynamics apply once the engagement is over.
+		t.LastEngagedAt = mission.CreatedAt
+	} else {
+		applyMissionThreat(t, mission, opts.Threat, mission.CreatedAt)
+	}
+	updated := *t
+	st.record(EventDispatched, mission.CreatedAt, DispatchedEvent{Mission: mission, Deployed: deployedDelta(before, st.Nodes), Target: &updated, MissionSeq: seq, Credited: credited})
 	return mission, nil
 }
 
+// ComputeRisk scores a dispatch with the standard risk model.
 func ComputeRisk(threat, units, capacity int) int {
-	pressure := float64(threat)*1.2 + float64(units)*0.6 - float64(capacity)*0.35
-	raw := int(math.Round(pressure / 2))
-	if raw < 1 {
-		return 1
-	}
-	if raw > 10 {
-		return 10
-	}
-	return raw
+	return standardModel.Risk(threat, units, capacity)
 }
 
+// OutcomeFromRisk maps a risk score to an outcome with the standard model.
 func OutcomeFromRisk(risk int, enoughCapacity bool) string {
-	if !enoughCapacity {
-		return "FAILED: insufficient fleet capacity"
-	}
-	if risk >= 8 {
-		return "EXTREME RESISTANCE"
-	}
-	if risk >= 5 {
-		return "CONTAINED"
-	}
-	return "NEUTRALIZED"
+	return standardModel.Outcome(risk, enoughCapacity)
 }
 
 func TotalCapacity(nodes []Node) int {
@@ -152,6 +275,9 @@ func AvailableCapacity(nodes []Node) int {
 }
 
 func NodeAvailable(n Node) int {
+	if n.Draining {
+		return 0
+	}
 	available := n.Capacity - n.Deployed
 	if available < 0 {
 		return 0
@@ -179,36 +305,100 @@ func consumeUnits(nodes []Node, units int) int {
 	return units - remaining
 }
 
+// recoverUnits returns units to draining nodes first so they empty out,
+// then to the remaining nodes in order.
 func recoverUnits(nodes []Node, units int) int {
 	remaining := units
-	for i := range nodes {
-		if remaining == 0 {
-			break
+	for _, draining := range []bool{true, false} {
+		for i := range nodes {
+			if remaining == 0 {
+				break
+			}
+			if nodes[i].Draining != draining || nodes[i].Deployed == 0 {
+				continue
+			}
+			recover := remaining
+			if recover > nodes[i].Deployed {
+				recover = nodes[i].Deployed
+			}
+			nodes[i].Deployed -= recover
+			remaining -= recover
+		}
+	}
+	return units - remaining
+}
+
+func deployedCounts(nodes []Node) []int {
+	out := make([]int, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.Deployed
+	}
+	return out
+}
+
+// missionLedger builds the per-node assignments from the deployed counts
+// before consumption, after consumption and now (after recovery). Strategies
+// may recover units that earlier missions left deployed, such as from
+// draining nodes; a node never gets back more than it supplied to this
+// mission, and the units recovered beyond that are returned per node.
+func missionLedger(nodes []Node, start, afterConsume []int) ([]NodeAssignment, map[string]int) {
+	var ledger []NodeAssignment
+	excess := map[string]int{}
+	for i, n := range nodes {
+		a := NodeAssignment{Node: n.Name, Consumed: afterConsume[i] - start[i], Recovered: afterConsume[i] - n.Deployed}
+		if a.Recovered > a.Consumed {
+			excess[n.Name] = a.Recovered - a.Consumed
+			a.Recovered = a.Consumed
 		}
-		if nodes[i].Deployed == 0 {
+		if a.Consumed == 0 {
 			continue
 		}
-		recover := remaining
-		if recover > nodes[i].Deployed {
-			recover = nodes[i].Deployed
+		a.NetLoss = a.Consumed - a.Recovered
+		ledger = append(ledger, a)
+	}
+	return ledger, excess
+}
+
+// creditEarlierMissions books units recovered beyond a mission's own supply
+// against the settled missions that left them deployed, oldest first, and
+// returns the missions it changed. Units no ledger accounts for, such as
+// those already repaired by ticks, are not booked.
+func creditEarlierMissions(st *State, excess map[string]int) []Mission {
+	var credited []Mission
+	for i := range st.Missions {
+		m := &st.Missions[i]
+		if m.InFlight() {
+			continue
+		}
+		changed := false
+		for j := range m.Assignments {
+			a := &m.Assignments[j]
+			units := min(excess[a.Node], a.NetLoss)
+			if units <= 0 {
+				continue
+			}
+			a.Recovered += units
+			a.NetLoss -= units
+			m.Recovered += units
+			m.NetLoss -= units
+			excess[a.Node] -= units
+			changed = true
+		}
+		if changed {
+			credited = append(credited, *m)
 		}
-		nodes[i].Deployed -= recover
-		remaining -= recover
 	}
-	return units - remaining
+	return credited
 }
 
-func recoveryRate(outcome string) float64 {
-	switch outcome {
-	case "NEUTRALIZED":
-		return 0.8
-	case "CONTAINED":
-		return 0.5
-	case "EXTREME RESISTANCE":
-		return 0.2
-	default:
-		return 0
+func selectedIndexes(nodes []Node, sel Selector) []int {
+	out := make([]int, 0, len(nodes))
+	for i, n := range nodes {
+		if sel.Matches(n) {
+			out = append(out, i)
+		}
 	}
+	return out
 }
 
 func findTarget(st *State, targetName string) (Target, bool) {
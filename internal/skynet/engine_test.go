@@ -2,6 +2,41 @@ package skynet
 
 import "testing"
 
+// fleetSpec describes the fleet built by testFleet.
+type fleetSpec struct {
+	// Start, if set, starts the simulated clock there before the fleet
+	// is added.
+	Start   string
+	Nodes   []Node
+	Targets []Target
+}
+
+// testFleet returns an awakened state holding the nodes and targets of
+// spec, failing the test if any of them cannot be added. Node Deployed
+// units are set directly, as if left out by earlier missions.
+func testFleet(t *testing.T, spec fleetSpec) State {
+	t.Helper()
+	st := NewState()
+	Awaken(&st, "defense")
+	if spec.Start != "" {
+		if _, err := Tick(&st, 1, SimulationConfig{Start: spec.Start}, ThreatDynamics{}); err != nil {
+			t.Fatalf("start clock: %v", err)
+		}
+	}
+	for _, n := range spec.Nodes {
+		if err := AddLabeledNode(&st, n.Name, n.Capacity, n.Labels); err != nil {
+			t.Fatalf("add node: %v", err)
+		}
+		st.Nodes[len(st.Nodes)-1].Deployed = n.Deployed
+	}
+	for _, target := range spec.Targets {
+		if err := AddTarget(&st, target.Name, target.Threat); err != nil {
+			t.Fatalf("add target: %v", err)
+		}
+	}
+	return st
+}
+
 func TestAddNodeRequiresOnlineCore(t *testing.T) {
 	st := NewState()
 	if err := AddNode(&st, "alpha", 4); err == nil {
@@ -101,3 +136,157 @@ func TestComputeRiskBounds(t *testing.T) {
 		t.Fatalf("expected upper bound 10, got %d", got)
 	}
 }
+
+func TestDispatchRecordsNodeLedger(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+
+	mission, err := Dispatch(&st, "hq", 6)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	consumed, recovered := 0, 0
+	for _, a := range mission.Assignments {
+		consumed += a.Consumed
+		recovered += a.Recovered
+		if a.NetLoss != a.Consumed-a.Recovered {
+			t.Fatalf("inconsistent assignment: %+v", a)
+		}
+	}
+	if consumed != mission.Consumed || recovered != mission.Recovered {
+		t.Fatalf("ledger totals %d/%d do not match mission %+v", consumed, recovered, mission)
+	}
+	if len(mission.Assignments) != 2 || mission.Assignments[0].Node != "alpha" || mission.Assignments[0].Consumed != 3 {
+		t.Fatalf("unexpected ledger: %+v", mission.Assignments)
+	}
+}
+
+func TestBalancedLedgerNeverRecoversMoreThanConsumed(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}, {Name: "beta", Capacity: 4}},
+		Targets: []Target{{Name: "hq", Threat: 1}},
+	})
+	// Units left on alpha by an earlier mission attract balanced recovery.
+	st.Nodes[0].Deployed = 6
+
+	mission, err := DispatchWith(&st, "hq", 4, DispatchOptions{Strategy: "balanced"})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if st.Nodes[0].Deployed != 5 || st.Nodes[1].Deployed != 2 {
+		t.Fatalf("fixture should recover 3 units from alpha: %+v", st.Nodes)
+	}
+	consumed, recovered := 0, 0
+	for _, a := range mission.Assignments {
+		if a.Recovered > a.Consumed || a.NetLoss != a.Consumed-a.Recovered {
+			t.Fatalf("ledger entry recovers more than it supplied: %+v", a)
+		}
+		consumed += a.Consumed
+		recovered += a.Recovered
+	}
+	if consumed != mission.Consumed || recovered != mission.Recovered {
+		t.Fatalf("ledger totals %d/%d do not match mission %+v", consumed, recovered, mission)
+	}
+}
+
+func TestCrossMissionRecoveryCreditsEarlierLedgers(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+	first, err := Dispatch(&st, "hq", 3)
+	if err != nil || first.NetLoss != 1 {
+		t.Fatalf("fixture should leave one unit on alpha: %+v err=%v", first, err)
+	}
+	// First-fit recovery of the second mission empties alpha, including
+	// the unit the first mission left there.
+	if _, err := Dispatch(&st, "hq", 5); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	ledger := map[string]int{}
+	for _, m := range st.Missions {
+		for _, a := range m.Assignments {
+			if a.Recovered > a.Consumed || a.NetLoss != a.Consumed-a.Recovered {
+				t.Fatalf("inconsistent ledger entry in %s: %+v", m.ID, a)
+			}
+			ledger[a.Node] += a.NetLoss
+		}
+	}
+	for _, n := range st.Nodes {
+		if ledger[n.Name] != n.Deployed {
+			t.Fatalf("ledger net loss %d on %s, deployed %d", ledger[n.Name], n.Name, n.Deployed)
+		}
+	}
+	if st.Missions[0].NetLoss != 0 || st.Missions[0].Recovered != 3 {
+		t.Fatalf("earlier mission not credited: %+v", st.Missions[0])
+	}
+
+	replayed := NewState()
+	for _, ev := range st.PendingEvents() {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if replayed.Missions[0].NetLoss != 0 || replayed.Missions[1].NetLoss != st.Missions[1].NetLoss {
+		t.Fatalf("replay lost the credit: %+v", replayed.Missions)
+	}
+}
+
+func TestPreviewDispatchDoesNotMutate(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 3}},
+		Targets: []Target{{Name: "hq", Threat: 4}},
+	})
+	pending := len(st.PendingEvents())
+	opts := DispatchOptions{Threat: ThreatDynamics{ReduceOnNeutralized: 2}}
+
+	preview, err := PreviewDispatch(st, "hq", 4, opts)
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if len(st.Missions) != 0 || st.Nodes[0].Deployed != 0 || st.Targets[0].Threat != 4 || len(st.Targets[0].ThreatHistory) != 1 {
+		t.Fatalf("preview mutated state: %+v", st)
+	}
+	if len(st.PendingEvents()) != pending {
+		t.Fatal("preview recorded events")
+	}
+	if preview.AvailableBefore != 6 || preview.AvailableAfter != 6-preview.Mission.NetLoss || preview.Target.Threat != 2 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+
+	mission, err := DispatchWith(&st, "hq", 4, opts)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if mission.Consumed != preview.Mission.Consumed || mission.Recovered != preview.Mission.Recovered || len(mission.Assignments) != len(preview.Mission.Assignments) {
+		t.Fatalf("preview %+v does not match dispatch %+v", preview.Mission, mission)
+	}
+}
+
+func TestDispatchPartialDeploysAvailableUnits(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}},
+		Targets: []Target{{Name: "hq", Threat: 4}},
+	})
+
+	strict, err := Dispatch(&st, "hq", 8)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if strict.Consumed != 0 || strict.UnderStrength() {
+		t.Fatalf("default dispatch should stay all-or-nothing: %+v", strict)
+	}
+
+	partial, err := DispatchWith(&st, "hq", 8, DispatchOptions{Partial: true})
+	if err != nil {
+		t.Fatalf("partial dispatch: %v", err)
+	}
+	if partial.Consumed != 3 || partial.Shortfall != 5 || !partial.UnderStrength() || partial.Units != 8 {
+		t.Fatalf("unexpected partial mission: %+v", partial)
+	}
+	if partial.RiskScore != ComputeRisk(4, 3, 3) || partial.Outcome == strict.Outcome {
+		t.Fatalf("risk should be recomputed for the reduced force: %+v", partial)
+	}
+}
@@ -0,0 +1,358 @@
+package skynet
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	EventAwaken         = "awaken"
+	EventNodeAdded      = "node_added"
+	EventNodeUpdated    = "node_updated"
+	EventNodeRemoved    = "node_removed"
+	EventTargetSet      = "target_set"
+	EventTargetRemoved  = "target_removed"
+	EventTick           = "tick"
+	EventMissionUpdated = "mission_updated"
+	EventDispatched     = "dispatched"
+	// EventMissionsArchived removes missions moved to the mission archive.
+	EventMissionsArchived = "missions_archived"
+	// EventStateReplaced carries a full state document. Stores append it when
+	// a save contains changes that the typed events do not account for.
+	EventStateReplaced = "state_replaced"
+)
+
+// Event is one typed mutation in the state journal. Payloads record the
+// effect of the mutation (timestamps, mission IDs, deployment deltas) so that
+// replay is deterministic and independent of later engine changes.
+type Event struct {
+	Seq  int             `json:"seq"`
+	Type string          `json:"type"`
+	At   string          `json:"at"`
+	Data json.RawMessage `json:"data"`
+}
+
+type AwakenEvent struct {
+	Mode string `json:"mode"`
+}
+
+type NodeAddedEvent struct {
+	Node Node `json:"node"`
+}
+
+// NodeUpdatedEvent replaces the node previously called Name.
+type NodeUpdatedEvent struct {
+	Name string `json:"name"`
+	Node Node   `json:"node"`
+}
+
+type NodeRemovedEvent struct {
+	Name string `json:"name"`
+}
+
+type TargetSetEvent struct {
+	Target Target `json:"target"`
+}
+
+type TargetRemovedEvent struct {
+	Name string `json:"name"`
+}
+
+// MissionUpdatedEvent replaces a mission after dispatch and applies the
+// resulting deployed unit deltas.
+type MissionUpdatedEvent struct {
+	Mission  Mission        `json:"mission"`
+	Deployed map[string]int `json:"deployed"`
+}
+
+type TickEvent struct {
+	Ticks    int            `json:"ticks"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Repaired map[string]int `json:"repaired,omitempty"`
+	Targets  []Target       `json:"targets,omitempty"`
+	// Missions replaces in-flight missions that changed phase; Returned
+	// holds the units they brought back on closing.
+	Missions []Mission      `json:"missions,omitempty"`
+	Returned map[string]int `json:"returned,omitempty"`
+}
+
+type DispatchedEvent struct {
+	Mission Mission `json:"mission"`
+	// Deployed maps node name to the net change of its deployed units.
+	Deployed map[string]int `json:"deployed"`
+	// Target is the target after threat dynamics; absent in old journals.
+	Target *Target `json:"target,omitempty"`
+	// MissionSeq is the mission counter after the dispatch.
+	MissionSeq int `json:"mission_seq,omitempty"`
+	// Credited holds earlier missions whose outstanding units this
+	// dispatch recovered, as they are afterwards.
+	Credited []Mission `json:"credited,omitempty"`
+}
+
+type MissionsArchivedEvent struct {
+	IDs []string `json:"ids"`
+}
+
+type StateReplacedEvent struct {
+	State json.RawMessage `json:"state"`
+}
+
+// record queues an event on the state for stores that keep a journal.
+func (st *State) record(eventType, at string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		panic(fmt.Sprintf("skynet: encode %s event: %v", eventType, err))
+	}
+	st.events = append(st.events, Event{Type: eventType, At: at, Data: data})
+}
+
+// PendingEvents returns the events recorded since the state was loaded.
+func (st State) PendingEvents() []Event {
+	return append([]Event(nil), st.events...)
+}
+
+func ApplyEvent(st *State, ev Event) error {
+	switch ev.Type {
+	case EventAwaken:
+		var p AwakenEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		st.Core.Online = true
+		st.Core.Mode = p.Mode
+		st.Core.LastAwaken = ev.At
+	case EventNodeAdded:
+		var p NodeAddedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		st.Nodes = append(st.Nodes, p.Node)
+	case EventNodeUpdated:
+		var p NodeUpdatedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		idx := findNodeIndex(st.Nodes, p.Name)
+		if idx < 0 {
+			return eventError(ev, fmt.Errorf("node %q not found", p.Name))
+		}
+		renameLedgerNode(st, st.Nodes[idx].Name, p.Node.Name)
+		st.Nodes[idx] = p.Node
+	case EventNodeRemoved:
+		var p NodeRemovedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		idx := findNodeIndex(st.Nodes, p.Name)
+		if idx < 0 {
+			return eventError(ev, fmt.Errorf("node %q not found", p.Name))
+		}
+		st.Nodes = append(st.Nodes[:idx:idx], st.Nodes[idx+1:]...)
+	case EventTargetSet:
+		var p TargetSetEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		idx := findTargetIndex(st.Targets, p.Target.Name)
+		if p.Target.CreatedAt == "" {
+			// Journals written before schema v2 carry added_at only.
+			p.Target = upgradeLegacyTarget(st.Targets, idx, p.Target, ev.At)
+		}
+		if idx >= 0 {
+			st.Targets[idx] = p.Target
+			return nil
+		}
+		st.Targets = append(st.Targets, p.Target)
+	case EventMissionUpdated:
+		var p MissionUpdatedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		idx := findMissionIndex(st.Missions, p.Mission.ID)
+		if idx < 0 {
+			return eventError(ev, fmt.Errorf("mission %q not found", p.Mission.ID))
+		}
+		st.Missions[idx] = p.Mission
+		for i := range st.Nodes {
+			st.Nodes[i].Deployed += p.Deployed[st.Nodes[i].Name]
+		}
+	case EventTick:
+		var p TickEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		for _, units := range []map[string]int{p.Repaired, p.Returned} {
+			for name, n := range units {
+				idx := findNodeIndex(st.Nodes, name)
+				if idx < 0 {
+					return eventError(ev, fmt.Errorf("node %q not found", name))
+				}
+				st.Nodes[idx].Deployed -= n
+			}
+		}
+		for _, m := range p.Missions {
+			idx := findMissionIndex(st.Missions, m.ID)
+			if idx < 0 {
+				return eventError(ev, fmt.Errorf("mission %q not found", m.ID))
+			}
+			st.Missions[idx] = m
+		}
+		for _, t := range p.Targets {
+			idx := findTargetIndex(st.Targets, t.Name)
+			if idx < 0 {
+				return eventError(ev, fmt.Errorf("target %q not found", t.Name))
+			}
+			st.Targets[idx] = t
+		}
+		st.Core.SimTime = p.To
+		st.Core.Ticks += p.Ticks
+	case EventTargetRemoved:
+		var p TargetRemovedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		idx := findTargetIndex(st.Targets, p.Name)
+		if idx < 0 {
+			return eventError(ev, fmt.Errorf("target %q not found", p.Name))
+		}
+		st.Targets = append(st.Targets[:idx:idx], st.Targets[idx+1:]...)
+	case EventDispatched:
+		var p DispatchedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		for i := range st.Nodes {
+			st.Nodes[i].Deployed += p.Deployed[st.Nodes[i].Name]
+		}
+		for _, m := range p.Credited {
+			if idx := findMissionIndex(st.Missions, m.ID); idx >= 0 {
+				st.Missions[idx] = m
+			}
+		}
+		st.Missions = append(st.Missions, p.Mission)
+		st.Core.LastMission = p.Mission.CreatedAt
+		st.Core.MissionSeq = max(st.Core.MissionSeq, p.MissionSeq)
+		if p.Target != nil {
+			if idx := findTargetIndex(st.Targets, p.Target.Name); idx >= 0 {
+				st.Targets[idx] = *p.Target
+			}
+		}
+	case EventMissionsArchived:
+		var p MissionsArchivedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		archived := make(map[string]bool, len(p.IDs))
+		for _, id := range p.IDs {
+			archived[id] = true
+		}
+		kept := make([]Mission, 0, len(st.Missions))
+		for _, m := range st.Missions {
+			if !archived[m.ID] {
+				kept = append(kept, m)
+			}
+		}
+		st.Missions = kept
+	case EventStateReplaced:
+		var p StateReplacedEvent
+		if err := json.Unmarshal(ev.Data, &p); err != nil {
+			return eventError(ev, err)
+		}
+		replaced, err := decodeState(p.State)
+		if err != nil {
+			return eventError(ev, err)
+		}
+		*st = replaced
+	default:
+		return fmt.Errorf("event %d: unknown type %q", ev.Seq, ev.Type)
+	}
+	return nil
+}
+
+func eventError(ev Event, err error) error {
+	return fmt.Errorf("event %d (%s): %w", ev.Seq, ev.Type, err)
+}
+
+func deployedByNode(nodes []Node) map[string]int {
+	deployed := make(map[string]int, len(nodes))
+	for _, n := range nodes {
+		deployed[n.Name] = n.Deployed
+	}
+	return deployed
+}
+
+func deployedDelta(before map[string]int, nodes []Node) map[string]int {
+	delta := map[string]int{}
+	for _, n := range nodes {
+		if d := n.Deployed - before[n.Name]; d != 0 {
+			delta[n.Name] = d
+		}
+	}
+	return delta
+}
+
+// Describe returns a one-line human summary of the event payload.
+func (ev Event) Describe() string {
+	switch ev.Type {
+	case EventAwaken:
+		var p AwakenEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("mode=%s", p.Mode)
+		}
+	case EventNodeAdded:
+		var p NodeAddedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("node=%s capacity=%d", p.Node.Name, p.Node.Capacity)
+		}
+	case EventNodeUpdated:
+		var p NodeUpdatedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("node=%s capacity=%d deployed=%d status=%s", p.Node.Name, p.Node.Capacity, p.Node.Deployed, NodeStatus(p.Node))
+		}
+	case EventNodeRemoved:
+		var p NodeRemovedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("node=%s", p.Name)
+		}
+	case EventTargetSet:
+		var p TargetSetEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("target=%s threat=%d status=%s", p.Target.Name, p.Target.Threat, TargetStatus(p.Target))
+		}
+	case EventMissionUpdated:
+		var p MissionUpdatedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			m := p.Mission
+			return fmt.Sprintf("mission=%s status=%s recovered=%d net_loss=%d", m.ID, m.Status, m.Recovered, m.NetLoss)
+		}
+	case EventTick:
+		var p TickEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			total := 0
+			for _, units := range p.Repaired {
+				total += units
+			}
+			return fmt.Sprintf("ticks=%d to=%s repaired=%d missions=%d", p.Ticks, p.To, total, len(p.Missions))
+		}
+	case EventTargetRemoved:
+		var p TargetRemovedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("target=%s", p.Name)
+		}
+	case EventDispatched:
+		var p DispatchedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			m := p.Mission
+			return fmt.Sprintf("mission=%s target=%s units=%d outcome=%s net_loss=%d", m.ID, m.Target, m.Units, m.Outcome, m.NetLoss)
+		}
+	case EventMissionsArchived:
+		var p MissionsArchivedEvent
+		if json.Unmarshal(ev.Data, &p) == nil {
+			return fmt.Sprintf("missions=%d", len(p.IDs))
+		}
+	case EventStateReplaced:
+		return "full state snapshot"
+	}
+	return ""
+}
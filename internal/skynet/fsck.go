@@ -0,0 +1,190 @@
+package skynet
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Violation codes reported by Fsck.
+const (
+	CodeNodeNameEmpty        = "NODE_NAME_EMPTY"
+	CodeNodeNameSpace        = "NODE_NAME_SPACE"
+	CodeNodeDuplicate        = "NODE_DUPLICATE"
+	CodeNodeCapacity         = "NODE_CAPACITY"
+	CodeNodeDeployedNegative = "NODE_DEPLOYED_NEGATIVE"
+	CodeNodeOverdeployed     = "NODE_OVERDEPLOYED"
+	CodeNodeLockedUnits      = "NODE_LOCKED_UNITS"
+	CodeTargetNameEmpty      = "TARGET_NAME_EMPTY"
+	CodeTargetNameSpace      = "TARGET_NAME_SPACE"
+	CodeTargetDuplicate      = "TARGET_DUPLICATE"
+	CodeTargetThreatRange    = "TARGET_THREAT_RANGE"
+	CodeMissionDuplicateID   = "MISSION_DUPLICATE_ID"
+	CodeMissionUnknownTarget = "MISSION_UNKNOWN_TARGET"
+	CodeMissionUnits         = "MISSION_UNITS"
+	CodeMissionRecovery      = "MISSION_RECOVERY"
+	CodeMissionNetLoss       = "MISSION_NET_LOSS"
+	CodeMissionRiskRange     = "MISSION_RISK_RANGE"
+	CodeMissionLedger        = "MISSION_LEDGER"
+)
+
+type Violation struct {
+	Code       string `json:"code"`
+	Severity   string `json:"severity"`
+	Subject    string `json:"subject"`
+	Message    string `json:"message"`
+	Repairable bool   `json:"repairable"`
+	Repaired   bool   `json:"repaired"`
+}
+
+type FsckReport struct {
+	Violations []Violation `json:"violations"`
+	Repaired   int         `json:"repaired"`
+}
+
+// Errors counts error-level violations that were not repaired.
+func (r FsckReport) Errors() int {
+	count := 0
+	for _, v := range r.Violations {
+		if v.Severity == SeverityError && !v.Repaired {
+			count++
+		}
+	}
+	return count
+}
+
+// Fsck checks st against the invariants that AddNode, AddTarget and Dispatch
+// enforce. With repair set it also applies the mechanical fixes: trimming
+// names, recomputing net loss and clamping deployments and threats into
+// range. Clamping is lossy: units deployed beyond a node's capacity are
+// written off, as ResizeNode does with force. Violations without a single
+// correct fix, such as duplicate names, are only reported.
+func Fsck(st *State, repair bool) FsckReport {
+	report := FsckReport{Violations: []Violation{}}
+	add := func(code, severity, subject, message string, fix func()) {
+		v := Violation{Code: code, Severity: severity, Subject: subject, Message: message, Repairable: fix != nil}
+		if repair && fix != nil {
+			fix()
+			v.Repaired = true
+			report.Repaired++
+		}
+		report.Violations = append(report.Violations, v)
+	}
+
+	seenNodes := map[string]string{}
+	for i := range st.Nodes {
+		n := &st.Nodes[i]
+		subject := fmt.Sprintf("node %q", n.Name)
+		trimmed := strings.TrimSpace(n.Name)
+		switch {
+		case trimmed == "":
+			add(CodeNodeNameEmpty, SeverityError, fmt.Sprintf("node #%d", i+1), "node name is empty", nil)
+		case trimmed != n.Name:
+			var fix func()
+			if findNodeIndex(st.Nodes, trimmed) < 0 {
+				fix = func() { n.Name = trimmed }
+			}
+			add(CodeNodeNameSpace, SeverityWarning, subject, "node name has surrounding whitespace", fix)
+		}
+		key := strings.ToLower(trimmed)
+		if first, dup := seenNodes[key]; dup && trimmed != "" {
+			add(CodeNodeDuplicate, SeverityError, subject, fmt.Sprintf("duplicates node %q (names are case-insensitive)", first), nil)
+		} else {
+			seenNodes[key] = n.Name
+		}
+		if n.Capacity < 1 {
+			add(CodeNodeCapacity, SeverityError, subject, fmt.Sprintf("capacity %d must be >= 1", n.Capacity), nil)
+		}
+		if n.Deployed < 0 {
+			add(CodeNodeDeployedNegative, SeverityError, subject, fmt.Sprintf("deployed %d is negative", n.Deployed), func() { n.Deployed = 0 })
+		}
+		if n.Capacity >= 1 && n.Deployed > n.Capacity {
+			capacity := n.Capacity
+			add(CodeNodeOverdeployed, SeverityError, subject, fmt.Sprintf("deployed %d exceeds capacity %d", n.Deployed, n.Capacity), func() { n.Deployed = capacity })
+		}
+	}
+
+	seenTargets := map[string]string{}
+	for i := range st.Targets {
+		t := &st.Targets[i]
+		subject := fmt.Sprintf("target %q", t.Name)
+		trimmed := strings.TrimSpace(t.Name)
+		switch {
+		case trimmed == "":
+			add(CodeTargetNameEmpty, SeverityError, fmt.Sprintf("target #%d", i+1), "target name is empty", nil)
+		case trimmed != t.Name:
+			var fix func()
+			if findTargetIndex(st.Targets, trimmed) < 0 {
+				fix = func() { t.Name = trimmed }
+			}
+			add(CodeTargetNameSpace, SeverityWarning, subject, "target name has surrounding whitespace", fix)
+		}
+		key := strings.ToLower(trimmed)
+		if first, dup := seenTargets[key]; dup && trimmed != "" {
+			add(CodeTargetDuplicate, SeverityError, subject, fmt.Sprintf("duplicates target %q (names are case-insensitive)", first), nil)
+		} else {
+			seenTargets[key] = t.Name
+		}
+		if t.Threat < 1 || t.Threat > 10 {
+			clamped := clampThreat(t.Threat)
+			add(CodeTargetThreatRange, SeverityError, subject, fmt.Sprintf("threat %d is outside 1-10", t.Threat), func() { t.Threat = clamped })
+		}
+	}
+
+	seenMissions := map[string]bool{}
+	for i := range st.Missions {
+		m := &st.Missions[i]
+		subject := fmt.Sprintf("mission %s", m.ID)
+		if seenMissions[m.ID] {
+			add(CodeMissionDuplicateID, SeverityError, subject, "mission ID is not unique", nil)
+		}
+		seenMissions[m.ID] = true
+		if _, ok := seenTargets[strings.ToLower(strings.TrimSpace(m.Target))]; !ok {
+			add(CodeMissionUnknownTarget, SeverityWarning, subject, fmt.Sprintf("references unknown target %q", m.Target), nil)
+		}
+		if m.Units < 1 {
+			add(CodeMissionUnits, SeverityError, subject, fmt.Sprintf("units %d must be >= 1", m.Units), nil)
+		}
+		if m.Consumed < 0 || m.Recovered < 0 || m.Recovered > m.Consumed || m.Consumed > m.Units {
+			add(CodeMissionRecovery, SeverityError, subject, fmt.Sprintf("inconsistent resources: units=%d consumed=%d recovered=%d", m.Units, m.Consumed, m.Recovered), nil)
+		} else if m.NetLoss != m.Consumed-m.Recovered {
+			want := m.Consumed - m.Recovered
+			add(CodeMissionNetLoss, SeverityError, subject, fmt.Sprintf("net_loss %d != consumed-recovered %d", m.NetLoss, want), func() { m.NetLoss = want })
+		}
+		if m.RiskScore < 1 || m.RiskScore > 10 {
+			add(CodeMissionRiskRange, SeverityWarning, subject, fmt.Sprintf("risk score %d is outside 1-10", m.RiskScore), nil)
+		}
+		if len(m.Assignments) > 0 {
+			consumed, recovered := 0, 0
+			for _, a := range m.Assignments {
+				consumed += a.Consumed
+				recovered += a.Recovered
+			}
+			if consumed != m.Consumed || recovered != m.Recovered {
+				add(CodeMissionLedger, SeverityWarning, subject, fmt.Sprintf("node ledger totals consumed=%d recovered=%d do not match the mission", consumed, recovered), nil)
+			}
+		}
+	}
+
+	locked := lockedUnits(*st)
+	for _, n := range st.Nodes {
+		if units := locked[n.Name]; units > n.Deployed {
+			add(CodeNodeLockedUnits, SeverityError, fmt.Sprintf("node %q", n.Name), fmt.Sprintf("in-flight missions lock %d unit(s) but only %d are deployed", units, n.Deployed), nil)
+		}
+	}
+	return report
+}
+
+func clampThreat(threat int) int {
+	if threat < 1 {
+		return 1
+	}
+	if threat > 10 {
+		return 10
+	}
+	return threat
+}
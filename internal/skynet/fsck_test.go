@@ -0,0 +1,73 @@
+package skynet
+
+import "testing"
+
+func hasCode(report FsckReport, code string) bool {
+	for _, v := range report.Violations {
+		if v.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFsckReportsViolations(t *testing.T) {
+	st := NewState()
+	st.Nodes = []Node{
+		{Name: "alpha", Capacity: 5, Deployed: 9},
+		{Name: "ALPHA", Capacity: 3},
+	}
+	st.Targets = []Target{{Name: "hq", Threat: 14}}
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "ghost", Units: 3, Consumed: 3, Recovered: 1, NetLoss: 0, RiskScore: 4},
+	}
+	report := Fsck(&st, false)
+	for _, code := range []string{CodeNodeOverdeployed, CodeNodeDuplicate, CodeTargetThreatRange, CodeMissionUnknownTarget, CodeMissionNetLoss} {
+		if !hasCode(report, code) {
+			t.Fatalf("expected %s in %+v", code, report.Violations)
+		}
+	}
+	if report.Repaired != 0 || st.Nodes[0].Deployed != 9 {
+		t.Fatal("check without repair must not modify state")
+	}
+}
+
+func TestFsckRepairAppliesSafeFixes(t *testing.T) {
+	st := NewState()
+	st.Nodes = []Node{
+		{Name: "alpha", Capacity: 5, Deployed: 9},
+		{Name: "ALPHA", Capacity: 3},
+	}
+	st.Targets = []Target{{Name: "hq", Threat: 14}}
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "ghost", Units: 3, Consumed: 3, Recovered: 1, NetLoss: 0, RiskScore: 4},
+	}
+	report := Fsck(&st, true)
+	if report.Repaired != 3 {
+		t.Fatalf("expected 3 repairs, got %d", report.Repaired)
+	}
+	if st.Nodes[0].Deployed != 5 || st.Targets[0].Threat != 10 || st.Missions[0].NetLoss != 2 {
+		t.Fatalf("unexpected repaired state: nodes=%+v targets=%+v missions=%+v", st.Nodes, st.Targets, st.Missions)
+	}
+	if report.Errors() != 1 {
+		t.Fatalf("expected only the duplicate node to remain, got %d errors", report.Errors())
+	}
+
+	again := Fsck(&st, false)
+	if hasCode(again, CodeNodeOverdeployed) || hasCode(again, CodeMissionNetLoss) {
+		t.Fatalf("repaired violations reappeared: %+v", again.Violations)
+	}
+}
+
+func TestFsckCleanState(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 7}},
+	})
+	if _, err := Dispatch(&st, "hq", 4); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if report := Fsck(&st, false); len(report.Violations) != 0 {
+		t.Fatalf("expected no violations, got %+v", report.Violations)
+	}
+}
@@ -55,15 +55,21 @@ func PlanGame(st State, budget int, beta float64) (GamePlan, error) {
 	if budget < 0 {
 		return GamePlan{}, fmt.Errorf("budget must be >= 0")
 	}
-	if len(st.Targets) == 0 {
-		return GamePlan{}, fmt.Errorf("at least one target is required")
+	active := make([]Target, 0, len(st.Targets))
+	for _, t := range st.Targets {
+		if !t.Archived {
+			active = append(active, t)
+		}
+	}
+	if len(active) == 0 {
+		return GamePlan{}, fmt.Errorf("at least one active target is required")
 	}
 	if beta <= 0 {
 		beta = defaultAttackBeta
 	}
 
-	targets := make([]GameTargetPlan, 0, len(st.Targets))
-	for _, t := range st.Targets {
+	targets := make([]GameTargetPlan, 0, len(active))
+	for _, t := range active {
 		targets = append(targets, GameTargetPlan{Name: t.Name, Threat: t.Threat})
 	}
 	sort.Slice(targets, func(i, j int) bool {
@@ -159,7 +165,18 @@ func attackProbabilities(targets []GameTargetPlan, beta float64) []float64 {
 	return probs
 }
 
+// PlayWarGame runs the war game on the random source of the engine attached
+// to st, so a fresh engine with the same seed repeats the game exactly.
+func PlayWarGame(st State, rounds, budget int, beta float64) (WarGameResult, error) {
+	engine := st.Engine()
+	return playWarGame(st, rounds, budget, beta, engine.Rand(), engine.Seed)
+}
+
 func RunWarGame(st State, rounds, budget int, beta float64, seed int64) (WarGameResult, error) {
+	return playWarGame(st, rounds, budget, beta, rand.New(rand.NewSource(seed)), seed)
+}
+
+func playWarGame(st State, rounds, budget int, beta float64, rng *rand.Rand, seed int64) (WarGameResult, error) {
 	if rounds < 1 {
 		return WarGameResult{}, fmt.Errorf("rounds must be >= 1")
 	}
@@ -177,7 +194,6 @@ func RunWarGame(st State, rounds, budget int, beta float64, seed int64) (WarGame
 		}
 	}
 
-	rng := rand.New(rand.NewSource(seed))
 	totalLoss := 0.0
 	maxRoundLoss := 0.0
 
@@ -105,3 +105,21 @@ func TestRunWarGameRoundsValidation(t *testing.T) {
 		t.Fatal("expected error for rounds <= 0")
 	}
 }
+
+func TestPlayWarGameUsesEngineSeed(t *testing.T) {
+	st := NewState()
+	st.Targets = []Target{{Name: "alpha", Threat: 9}, {Name: "beta", Threat: 6}}
+	st.SetEngine(NewEngine(nil, 7))
+
+	played, err := PlayWarGame(st, 50, 10, 1.2)
+	if err != nil {
+		t.Fatalf("play wargame: %v", err)
+	}
+	seeded, err := RunWarGame(st, 50, 10, 1.2, 7)
+	if err != nil {
+		t.Fatalf("run wargame: %v", err)
+	}
+	if played.Seed != 7 || played.TotalLoss != seeded.TotalLoss {
+		t.Fatalf("expected engine seed 7 to match: %+v vs %+v", played, seeded)
+	}
+}
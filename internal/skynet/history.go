@@ -0,0 +1,275 @@
+package skynet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	historyDir          = "history"
+	historyIndexFile    = "index.json"
+	defaultHistoryLimit = 200
+)
+
+// Revision is one saved state in the undo history.
+type Revision struct {
+	ID      int    `json:"id"`
+	Command string `json:"command"`
+	At      string `json:"at"`
+}
+
+type HistoryLog struct {
+	Cursor    int        `json:"cursor"`
+	NextID    int        `json:"next_id"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// HistoryStore wraps a Store and records a full-state revision, labelled
+// with Command, every time a state is saved through it. Undo and Redo move
+// the cursor between revisions and save through the wrapped store without
+// recording new revisions.
+type HistoryStore struct {
+	Store
+	Dir     string
+	Command string
+	Limit   int
+	// Key encrypts revisions and the index; it defaults to the inner store's.
+	Key *StateKey
+}
+
+func WithHistory(inner Store, path, command string) HistoryStore {
+	return HistoryStore{
+		Store:   inner,
+		Dir:     filepath.Join(filepath.Dir(path), historyDir),
+		Command: command,
+		Limit:   defaultHistoryLimit,
+		Key:     storeKey(inner),
+	}
+}
+
+func (h HistoryStore) Save(st State) error {
+	log, err := h.readLog()
+	if err != nil {
+		return err
+	}
+	current, err := h.Store.Load()
+	if err != nil {
+		return err
+	}
+	// Revisions recorded on the way are stamped by the saving engine.
+	current.SetEngine(st.engine)
+	if err := h.syncExternal(&log, current); err != nil {
+		return err
+	}
+	if err := h.Store.Save(st); err != nil {
+		return err
+	}
+	if err := h.truncateRedo(&log); err != nil {
+		return err
+	}
+	if err := h.record(&log, st, h.Command); err != nil {
+		return err
+	}
+	if err := h.prune(&log); err != nil {
+		return err
+	}
+	return h.writeLog(log)
+}
+
+func (h HistoryStore) History() (HistoryLog, error) {
+	return h.readLog()
+}
+
+func (h HistoryStore) LoadRevision(id int) (State, error) {
+	data, err := readSealedFile(h.revisionPath(id), h.Key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return State{}, fmt.Errorf("revision %d not found in history", id)
+		}
+		return State{}, err
+	}
+	return decodeState(data)
+}
+
+// Undo restores the state recorded steps revisions before the cursor.
+func (h HistoryStore) Undo(steps int) (Revision, error) {
+	if steps < 1 {
+		return Revision{}, fmt.Errorf("steps must be >= 1")
+	}
+	return h.move(-steps)
+}
+
+// Redo re-applies revisions undone since the last recorded command.
+func (h HistoryStore) Redo(steps int) (Revision, error) {
+	if steps < 1 {
+		return Revision{}, fmt.Errorf("steps must be >= 1")
+	}
+	return h.move(steps)
+}
+
+func (h HistoryStore) move(delta int) (Revision, error) {
+	log, err := h.readLog()
+	if err != nil {
+		return Revision{}, err
+	}
+	current, err := h.Store.Load()
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := h.syncExternal(&log, current); err != nil {
+		return Revision{}, err
+	}
+
+	pos := log.cursorIndex()
+	target := pos + delta
+	if target < 0 {
+		if pos <= 0 {
+			return Revision{}, fmt.Errorf("nothing to undo")
+		}
+		return Revision{}, fmt.Errorf("only %d revision(s) to undo", pos)
+	}
+	if target >= len(log.Revisions) {
+		if pos >= len(log.Revisions)-1 {
+			return Revision{}, fmt.Errorf("nothing to redo")
+		}
+		return Revision{}, fmt.Errorf("only %d revision(s) to redo", len(log.Revisions)-1-pos)
+	}
+
+	rev := log.Revisions[target]
+	st, err := h.LoadRevision(rev.ID)
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := h.Store.Save(st); err != nil {
+		return Revision{}, err
+	}
+	log.Cursor = rev.ID
+	if err := h.writeLog(log); err != nil {
+		return Revision{}, err
+	}
+	return rev, nil
+}
+
+// syncExternal makes sure the current on-disk state is the revision at the
+// cursor, recording it first if it was changed outside the history (or if
+// this is the first recorded command).
+func (h HistoryStore) syncExternal(log *HistoryLog, current State) error {
+	if len(log.Revisions) == 0 {
+		return h.record(log, current, "(initial)")
+	}
+	recorded, err := h.LoadRevision(log.Cursor)
+	if err != nil {
+		return err
+	}
+	same, err := sameState(recorded, current)
+	if err != nil || same {
+		return err
+	}
+	if err := h.truncateRedo(log); err != nil {
+		return err
+	}
+	return h.record(log, current, "(external change)")
+}
+
+func (h HistoryStore) record(log *HistoryLog, st State, command string) error {
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		return err
+	}
+	data, err := canonicalState(st)
+	if err != nil {
+		return err
+	}
+	if log.NextID < 1 {
+		log.NextID = 1
+	}
+	rev := Revision{ID: log.NextID, Command: command, At: st.timestamp()}
+	if err := writeSealedFile(h.revisionPath(rev.ID), data, h.Key); err != nil {
+		return err
+	}
+	log.NextID++
+	log.Revisions = append(log.Revisions, rev)
+	log.Cursor = rev.ID
+	return nil
+}
+
+func (h HistoryStore) truncateRedo(log *HistoryLog) error {
+	pos := log.cursorIndex()
+	for _, rev := range log.Revisions[pos+1:] {
+		if err := os.Remove(h.revisionPath(rev.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	log.Revisions = log.Revisions[:pos+1]
+	return nil
+}
+
+func (h HistoryStore) prune(log *HistoryLog) error {
+	limit := h.Limit
+	if limit < 1 {
+		limit = defaultHistoryLimit
+	}
+	for len(log.Revisions) > limit && log.Revisions[0].ID != log.Cursor {
+		if err := os.Remove(h.revisionPath(log.Revisions[0].ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		log.Revisions = log.Revisions[1:]
+	}
+	return nil
+}
+
+func (h HistoryStore) readLog() (HistoryLog, error) {
+	data, err := readSealedFile(filepath.Join(h.Dir, historyIndexFile), h.Key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return HistoryLog{NextID: 1, Revisions: []Revision{}}, nil
+		}
+		return HistoryLog{}, err
+	}
+	var log HistoryLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return HistoryLog{}, fmt.Errorf("read history index: %w", err)
+	}
+	return log, nil
+}
+
+func (h HistoryStore) writeLog(log HistoryLog) error {
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSealedFile(filepath.Join(h.Dir, historyIndexFile), data, h.Key)
+}
+
+func (h HistoryStore) revisionPath(id int) string {
+	return filepath.Join(h.Dir, fmt.Sprintf("rev-%06d.json", id))
+}
+
+// cursorIndex returns the position of the cursor revision, or -1 for an
+// empty history.
+func (log HistoryLog) cursorIndex() int {
+	for i, rev := range log.Revisions {
+		if rev.ID == log.Cursor {
+			return i
+		}
+	}
+	return len(log.Revisions) - 1
+}
+
+func sameState(a, b State) (bool, error) {
+	left, err := canonicalState(a)
+	if err != nil {
+		return false, err
+	}
+	right, err := canonicalState(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(left, right), nil
+}
@@ -0,0 +1,95 @@
+package skynet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	inner := NewStore(path)
+
+	st := NewState()
+	Awaken(&st, "defense")
+	if err := WithHistory(inner, path, "awaken").Save(st); err != nil {
+		t.Fatalf("save awaken: %v", err)
+	}
+	if err := AddTarget(&st, "hq", 4); err != nil {
+		t.Fatalf("add target: %v", err)
+	}
+	if err := WithHistory(inner, path, "target -name hq").Save(st); err != nil {
+		t.Fatalf("save target: %v", err)
+	}
+
+	history := WithHistory(inner, path, "")
+	log, err := history.History()
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(log.Revisions) != 3 || log.Revisions[0].Command != "(initial)" || log.Revisions[2].Command != "target -name hq" {
+		t.Fatalf("unexpected revisions: %+v", log.Revisions)
+	}
+
+	rev, err := history.Undo(1)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if rev.Command != "awaken" {
+		t.Fatalf("expected to restore awaken revision, got %q", rev.Command)
+	}
+	loaded, err := inner.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(loaded.Targets) != 0 || !loaded.Core.Online {
+		t.Fatalf("undo did not restore state: targets=%d online=%v", len(loaded.Targets), loaded.Core.Online)
+	}
+
+	if _, err := history.Redo(1); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	loaded, err = inner.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(loaded.Targets) != 1 {
+		t.Fatalf("redo did not restore target, got %d", len(loaded.Targets))
+	}
+	if _, err := history.Redo(1); err == nil {
+		t.Fatal("expected nothing to redo")
+	}
+	if _, err := history.Undo(5); err == nil {
+		t.Fatal("expected error when undoing past the first revision")
+	}
+}
+
+func TestHistoryRecordsExternalChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	inner := NewStore(path)
+
+	st := NewState()
+	Awaken(&st, "defense")
+	if err := WithHistory(inner, path, "awaken").Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	st.Core.Mode = "offense"
+	if err := inner.Save(st); err != nil {
+		t.Fatalf("external save: %v", err)
+	}
+
+	history := WithHistory(inner, path, "")
+	rev, err := history.Undo(1)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if rev.Command != "awaken" {
+		t.Fatalf("expected undo of external change to restore awaken, got %q", rev.Command)
+	}
+	log, err := history.History()
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if log.Revisions[len(log.Revisions)-1].Command != "(external change)" {
+		t.Fatalf("expected external change revision, got %+v", log.Revisions)
+	}
+}
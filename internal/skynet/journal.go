@@ -0,0 +1,378 @@
+package skynet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	journalFile          = "journal.jsonl"
+	journalSnapshotFile  = "journal.snapshot.json"
+	defaultSnapshotEvery = 50
+)
+
+// JournalStore persists State as an append-only JSONL log of typed events.
+// Load replays the log on top of the most recent snapshot; snapshots are a
+// cache only, the journal itself is never truncated.
+type JournalStore struct {
+	// Path is the nominal state path; the journal lives in its directory.
+	Path          string
+	SnapshotEvery int
+	// Key encrypts every journal line and the snapshot when set.
+	Key *StateKey
+}
+
+type journalSnapshot struct {
+	Seq   int             `json:"seq"`
+	At    string          `json:"at"`
+	State json.RawMessage `json:"state"`
+}
+
+func NewJournalStore(path string) JournalStore {
+	return JournalStore{Path: path, SnapshotEvery: defaultSnapshotEvery}
+}
+
+func journalExists(path string) bool {
+	_, err := os.Stat(filepath.Join(filepath.Dir(path), journalFile))
+	return err == nil
+}
+
+func (s JournalStore) JournalPath() string {
+	return filepath.Join(filepath.Dir(s.Path), journalFile)
+}
+
+func (s JournalStore) SnapshotPath() string {
+	return filepath.Join(filepath.Dir(s.Path), journalSnapshotFile)
+}
+
+func (s JournalStore) Lock(timeout time.Duration) (*Lock, error) {
+	return lockStatePath(s.Path, timeout)
+}
+
+func (s JournalStore) Load() (State, error) {
+	st, _, err := s.replay(nil)
+	return st, err
+}
+
+// LoadAt rebuilds the state as of the given instant: the journal up to the
+// last event recorded at or before it. Event times need not increase with
+// the sequence (clocks can be set back), so the cut is made by sequence
+// number and never skips an event in between.
+func (s JournalStore) LoadAt(at time.Time) (State, error) {
+	events, err := s.Events()
+	if err != nil {
+		return State{}, err
+	}
+	cutoff := 0
+	for _, ev := range events {
+		if t, err := time.Parse(time.RFC3339, ev.At); err == nil && !t.After(at) {
+			cutoff = ev.Seq
+		}
+	}
+	return s.LoadSeq(cutoff)
+}
+
+// LoadSeq rebuilds the state after the event with the given sequence number.
+func (s JournalStore) LoadSeq(seq int) (State, error) {
+	st, _, err := s.replay(func(ev Event) bool { return ev.Seq <= seq })
+	return st, err
+}
+
+func (s JournalStore) Events() ([]Event, error) {
+	return readJournal(s.JournalPath(), s.Key, 0)
+}
+
+// Save appends the events recorded on st since it was loaded. If replaying
+// them does not reproduce st exactly, a state_replaced event is appended so
+// the journal always converges on the saved state.
+func (s JournalStore) Save(st State) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	base, lastSeq, err := s.replay(nil)
+	if err != nil {
+		return err
+	}
+
+	pending := st.PendingEvents()
+	replayed := base
+	for _, ev := range pending {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			return err
+		}
+	}
+	want, err := canonicalState(st)
+	if err != nil {
+		return err
+	}
+	got, err := canonicalState(replayed)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		data, err := json.Marshal(StateReplacedEvent{State: want})
+		if err != nil {
+			return err
+		}
+		pending = append(pending, Event{Type: EventStateReplaced, At: st.timestamp(), Data: data})
+	}
+
+	if len(pending) > 0 {
+		if err := s.append(pending, lastSeq); err != nil {
+			return err
+		}
+	}
+	seq := lastSeq + len(pending)
+
+	snapSeq, snapVersion, err := s.snapshotInfo()
+	if err != nil {
+		return err
+	}
+	every := s.SnapshotEvery
+	if every < 1 {
+		every = defaultSnapshotEvery
+	}
+	if seq-snapSeq >= every || (snapSeq > 0 && snapVersion < CurrentSchemaVersion) {
+		return s.writeSnapshot(seq, st.timestamp(), want)
+	}
+	return nil
+}
+
+func (s JournalStore) PlanMigration() (MigrationPlan, error) {
+	data, err := readSealedFile(s.SnapshotPath(), s.Key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return MigrationPlan{Path: s.SnapshotPath(), FromVersion: CurrentSchemaVersion, ToVersion: CurrentSchemaVersion}, nil
+		}
+		return MigrationPlan{}, err
+	}
+	var snap journalSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return MigrationPlan{}, fmt.Errorf("read %s: %w", s.SnapshotPath(), err)
+	}
+	return planMigrationData(s.SnapshotPath(), snap.State)
+}
+
+// replay rebuilds state from the snapshot and journal. A nil filter uses the
+// snapshot as a starting point; a filter replays from the first event.
+func (s JournalStore) replay(include func(Event) bool) (State, int, error) {
+	st := NewState()
+	seq := 0
+	if include == nil {
+		snap, ok, err := s.readSnapshot()
+		if err != nil {
+			return State{}, 0, err
+		}
+		if ok {
+			restored, err := decodeState(snap.State)
+			if err != nil {
+				return State{}, 0, fmt.Errorf("read %s: %w", s.SnapshotPath(), err)
+			}
+			st = restored
+			seq = snap.Seq
+		}
+	}
+
+	events, err := readJournal(s.JournalPath(), s.Key, seq)
+	if err != nil {
+		return State{}, 0, err
+	}
+	for _, ev := range events {
+		if ev.Seq <= seq {
+			continue
+		}
+		if include != nil && !include(ev) {
+			break
+		}
+		if err := ApplyEvent(&st, ev); err != nil {
+			return State{}, 0, err
+		}
+		seq = ev.Seq
+	}
+	if include == nil && len(events) > 0 {
+		seq = events[len(events)-1].Seq
+	}
+	return st, seq, nil
+}
+
+func (s JournalStore) append(events []Event, lastSeq int) error {
+	f, err := os.OpenFile(s.JournalPath(), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := truncateTornTail(f); err != nil {
+		f.Close()
+		return err
+	}
+	var buf bytes.Buffer
+	for i, ev := range events {
+		ev.Seq = lastSeq + i + 1
+		line, err := json.Marshal(ev)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if line, err = s.Key.seal(line); err != nil {
+			f.Close()
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s JournalStore) readSnapshot() (journalSnapshot, bool, error) {
+	data, err := readSealedFile(s.SnapshotPath(), s.Key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return journalSnapshot{}, false, nil
+		}
+		return journalSnapshot{}, false, err
+	}
+	var snap journalSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return journalSnapshot{}, false, fmt.Errorf("read %s: %w", s.SnapshotPath(), err)
+	}
+	return snap, true, nil
+}
+
+func (s JournalStore) snapshotInfo() (int, int, error) {
+	snap, ok, err := s.readSnapshot()
+	if err != nil || !ok {
+		return 0, CurrentSchemaVersion, err
+	}
+	var head struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(snap.State, &head); err != nil {
+		return 0, 0, err
+	}
+	return snap.Seq, head.SchemaVersion, nil
+}
+
+func (s JournalStore) writeSnapshot(seq int, at string, state json.RawMessage) error {
+	data, err := json.MarshalIndent(journalSnapshot{Seq: seq, At: at, State: state}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSealedFile(s.SnapshotPath(), data, s.Key)
+}
+
+// truncateTornTail drops a trailing partial line left by an interrupted
+// append and positions f at the end of the last complete line.
+func truncateTornTail(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	end := size
+	buf := make([]byte, 4096)
+	for end > 0 {
+		chunk := int64(len(buf))
+		if chunk > end {
+			chunk = end
+		}
+		if _, err := f.ReadAt(buf[:chunk], end-chunk); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:chunk], '\n'); i >= 0 {
+			end = end - chunk + int64(i) + 1
+			break
+		}
+		end -= chunk
+	}
+	if end != size {
+		if err := f.Truncate(end); err != nil {
+			return err
+		}
+	}
+	_, err = f.Seek(end, 0)
+	return err
+}
+
+// readJournal reads the events of the journal at path. The first skip lines
+// are already covered by a snapshot and are not decrypted: line n holds the
+// event with Seq n. If the first event read does not match that, the whole
+// journal is read instead.
+func readJournal(path string, key *StateKey, skip int) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Event{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line, read := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if read++; read <= skip {
+			continue
+		}
+		data, err := key.open([]byte(text))
+		if errors.Is(err, ErrEncrypted) || errors.Is(err, ErrWrongKey) {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		var ev Event
+		if err == nil {
+			err = json.Unmarshal(data, &ev)
+		}
+		if err != nil {
+			// A torn final line is what a crash mid-append leaves behind;
+			// everything before it is intact.
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if skip > 0 && len(events) > 0 && events[0].Seq != skip+1 {
+		return readJournal(path, key, 0)
+	}
+	return events, nil
+}
+
+// canonicalState encodes st the way Save writes it, so that states built in
+// memory and states decoded from disk compare equal.
+func canonicalState(st State) ([]byte, error) {
+	st.SchemaVersion = CurrentSchemaVersion
+	if st.Nodes == nil {
+		st.Nodes = []Node{}
+	}
+	if st.Targets == nil {
+		st.Targets = []Target{}
+	}
+	if st.Missions == nil {
+		st.Missions = []Mission{}
+	}
+	return json.Marshal(st)
+}
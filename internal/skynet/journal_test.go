@@ -0,0 +1,169 @@
+package skynet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalStoreRecordsTypedEvents(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "state.json"))
+	if err := store.Save(testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 7}},
+	})); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, err := Dispatch(&st, "hq", 4); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if err := store.Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	events, err := store.Events()
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	want := []string{EventAwaken, EventNodeAdded, EventTargetSet, EventDispatched}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, ev := range events {
+		if ev.Type != want[i] || ev.Seq != i+1 {
+			t.Fatalf("event %d: expected #%d %s, got #%d %s", i, i+1, want[i], ev.Seq, ev.Type)
+		}
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(loaded.Missions) != 1 || AvailableCapacity(loaded.Nodes) != AvailableCapacity(st.Nodes) {
+		t.Fatalf("replayed state mismatch: missions=%d available=%d", len(loaded.Missions), AvailableCapacity(loaded.Nodes))
+	}
+
+	before, err := store.LoadSeq(3)
+	if err != nil {
+		t.Fatalf("load seq: %v", err)
+	}
+	if len(before.Missions) != 0 || AvailableCapacity(before.Nodes) != 10 {
+		t.Fatalf("expected pre-dispatch state at seq 3, got missions=%d available=%d", len(before.Missions), AvailableCapacity(before.Nodes))
+	}
+}
+
+func TestJournalStoreFallsBackToStateReplaced(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "state.json"))
+	if err := store.Save(testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 7}},
+	})); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	st, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	st.Nodes[0].Capacity = 3
+	if err := store.Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	events, err := store.Events()
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if last := events[len(events)-1]; last.Type != EventStateReplaced {
+		t.Fatalf("expected trailing %s event, got %s", EventStateReplaced, last.Type)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if loaded.Nodes[0].Capacity != 3 {
+		t.Fatalf("expected capacity=3, got %d", loaded.Nodes[0].Capacity)
+	}
+}
+
+func TestJournalStoreSnapshotsAndTornTail(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "state.json"))
+	store.SnapshotEvery = 2
+
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "alpha", Capacity: 5}}})
+	if err := store.Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(store.SnapshotPath()); err != nil {
+		t.Fatalf("expected snapshot after 2 events: %v", err)
+	}
+
+	f, err := os.OpenFile(store.JournalPath(), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	f.WriteString(`{"seq":3,"type":"node_add`)
+	f.Close()
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("load with torn tail: %v", err)
+	}
+	if err := AddNode(&loaded, "beta", 5); err != nil {
+		t.Fatalf("add node: %v", err)
+	}
+	if err := store.Save(loaded); err != nil {
+		t.Fatalf("save after torn tail: %v", err)
+	}
+	events, err := store.Events()
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	if len(events) != 3 || events[2].Type != EventNodeAdded {
+		t.Fatalf("expected torn line replaced by node_added #3, got %d events", len(events))
+	}
+}
+
+func TestJournalLoadAtCutsBySequence(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "state.json"))
+	at := func(hour int) time.Time { return time.Date(2030, 1, 1, hour, 0, 0, 0, time.UTC) }
+	steps := []struct {
+		hour int
+		run  func(st *State) error
+	}{
+		{10, func(st *State) error { Awaken(st, "defense"); return nil }},
+		{12, func(st *State) error { return AddNode(st, "alpha", 5) }},
+		// The clock was set back before the target was added.
+		{11, func(st *State) error { return AddTarget(st, "hq", 3) }},
+	}
+	for _, step := range steps {
+		st, err := store.Load()
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		st.SetEngine(NewEngine(FixedClock(at(step.hour)), 1))
+		if err := step.run(&st); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(st); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	st, err := store.LoadAt(at(11).Add(30 * time.Minute))
+	if err != nil {
+		t.Fatalf("load at: %v", err)
+	}
+	if len(st.Nodes) != 1 || len(st.Targets) != 1 {
+		t.Fatalf("expected every event up to the target, got nodes=%+v targets=%+v", st.Nodes, st.Targets)
+	}
+	if st, err = store.LoadAt(at(9)); err != nil || st.Core.Online {
+		t.Fatalf("expected an empty state before the first event: %+v err=%v", st.Core, err)
+	}
+}
@@ -0,0 +1,174 @@
+package skynet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseLabels parses "key=value" pairs separated by commas, e.g.
+// "region=east,role=air". Keys are lower-cased.
+func ParseLabels(spec string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, part := range splitList(spec) {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("label %q must be key=value", part)
+		}
+		key, err := labelKey(key)
+		if err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("label %q has an empty value", part)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// FormatLabels renders labels as a sorted "key=value,..." string.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + labels[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+// LabelNode sets and removes labels on a node.
+func LabelNode(st *State, name string, set map[string]string, remove []string) (Node, error) {
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return Node{}, err
+	}
+	node := &st.Nodes[idx]
+	labels := map[string]string{}
+	for k, v := range node.Labels {
+		labels[k] = v
+	}
+	for _, k := range remove {
+		key, err := labelKey(k)
+		if err != nil {
+			return Node{}, err
+		}
+		delete(labels, key)
+	}
+	for k, v := range set {
+		labels[k] = v
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	node.Labels = labels
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: node.Name, Node: *node})
+	return *node, nil
+}
+
+// SelectorTerm is one requirement of a Selector.
+type SelectorTerm struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Value string `json:"value,omitempty"`
+}
+
+// Selector picks nodes by label. Terms are ANDed; an empty selector
+// matches every node.
+type Selector []SelectorTerm
+
+// ParseSelector parses a comma-separated list of terms: "key=value",
+// "key!=value" or a bare "key" requiring the label to be present.
+func ParseSelector(spec string) (Selector, error) {
+	var sel Selector
+	for _, part := range splitList(spec) {
+		term := SelectorTerm{Op: "exists"}
+		key := part
+		if k, v, ok := strings.Cut(part, "!="); ok {
+			key, term.Op, term.Value = k, "!=", strings.TrimSpace(v)
+		} else if k, v, ok := strings.Cut(part, "="); ok {
+			key, term.Op, term.Value = k, "=", strings.TrimSpace(v)
+		}
+		var err error
+		if term.Key, err = labelKey(key); err != nil {
+			return nil, fmt.Errorf("selector %q: %w", part, err)
+		}
+		if term.Op != "exists" && term.Value == "" {
+			return nil, fmt.Errorf("selector %q has an empty value", part)
+		}
+		sel = append(sel, term)
+	}
+	return sel, nil
+}
+
+// Matches reports whether the node satisfies every term.
+func (s Selector) Matches(n Node) bool {
+	for _, term := range s {
+		value, ok := n.Labels[term.Key]
+		switch term.Op {
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "=":
+			if !ok || !strings.EqualFold(value, term.Value) {
+				return false
+			}
+		case "!=":
+			if ok && strings.EqualFold(value, term.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, term := range s {
+		switch term.Op {
+		case "exists":
+			parts[i] = term.Key
+		default:
+			parts[i] = term.Key + term.Op + term.Value
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// SelectNodes returns copies of the nodes matched by sel.
+func SelectNodes(nodes []Node, sel Selector) []Node {
+	out := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if sel.Matches(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func labelKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return "", fmt.Errorf("label key is required")
+	}
+	if strings.ContainsAny(key, " \t,=!") {
+		return "", fmt.Errorf("label key %q contains invalid characters", key)
+	}
+	return key, nil
+}
+
+func splitList(spec string) []string {
+	var out []string
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
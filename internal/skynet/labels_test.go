@@ -0,0 +1,66 @@
+package skynet
+
+import "testing"
+
+func TestParseSelectorAndMatch(t *testing.T) {
+	sel, err := ParseSelector("Region=east, role!=ground, tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel.String() != "region=east,role!=ground,tier" {
+		t.Fatalf("unexpected selector: %s", sel)
+	}
+	match := Node{Labels: map[string]string{"region": "EAST", "role": "air", "tier": "1"}}
+	if !sel.Matches(match) {
+		t.Fatal("expected node to match")
+	}
+	for _, labels := range []map[string]string{
+		{"region": "west", "tier": "1"},
+		{"region": "east", "role": "ground", "tier": "1"},
+		{"region": "east"},
+	} {
+		if sel.Matches(Node{Labels: labels}) {
+			t.Fatalf("unexpected match for %v", labels)
+		}
+	}
+	if _, err := ParseSelector("region="); err == nil {
+		t.Fatal("expected error for empty value")
+	}
+}
+
+func TestDispatchWithSelectorUsesMatchingNodes(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 5, Labels: map[string]string{"region": "east"}}, {Name: "beta", Capacity: 5, Labels: map[string]string{"region": "west"}}},
+		Targets: []Target{{Name: "hq", Threat: 2}},
+	})
+
+	sel, _ := ParseSelector("region=west")
+	mission, err := DispatchWith(&st, "hq", 4, DispatchOptions{Selector: sel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Nodes[0].Deployed != 0 || st.Nodes[1].Deployed != mission.NetLoss || mission.Selector != "region=west" {
+		t.Fatalf("unexpected deployment: nodes=%+v mission=%+v", st.Nodes, mission)
+	}
+
+	mission, err = DispatchWith(&st, "hq", 6, DispatchOptions{Selector: sel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mission.Consumed != 0 {
+		t.Fatalf("selector capacity should be insufficient: %+v", mission)
+	}
+}
+
+func TestLabelNodeSetsAndRemoves(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Labels: map[string]string{"region": "east", "role": "air"}}},
+	})
+	node, err := LabelNode(&st, "alpha", map[string]string{"tier": "2"}, []string{"ROLE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatLabels(node.Labels) != "region=east,tier=2" {
+		t.Fatalf("unexpected labels: %v", node.Labels)
+	}
+}
@@ -0,0 +1,196 @@
+package skynet
+
+import (
+	"fmt"
+	"math"
+)
+
+// Mission phases. Missions dispatched without a lifecycle are settled at
+// dispatch and carry no phase.
+const (
+	PhasePlanned   = "planned"
+	PhaseEnRoute   = "en_route"
+	PhaseEngaged   = "engaged"
+	PhaseReturning = "returning"
+	PhaseClosed    = "closed"
+)
+
+// phaseOrder is the order missions move through while in flight.
+var phaseOrder = []string{PhasePlanned, PhaseEnRoute, PhaseEngaged, PhaseReturning}
+
+// MissionLifecycle is the number of simulated ticks a mission spends in
+// each phase. Phases with zero ticks are skipped; the zero value settles
+// missions at dispatch as before.
+type MissionLifecycle struct {
+	Planned   int `json:"planned,omitempty"`
+	EnRoute   int `json:"en_route,omitempty"`
+	Engaged   int `json:"engaged,omitempty"`
+	Returning int `json:"returning,omitempty"`
+}
+
+// Enabled reports whether missions go through the phases over time.
+func (l MissionLifecycle) Enabled() bool {
+	return l.Planned+l.EnRoute+l.Engaged+l.Returning > 0
+}
+
+func (l MissionLifecycle) validate() error {
+	if l.Planned < 0 || l.EnRoute < 0 || l.Engaged < 0 || l.Returning < 0 {
+		return fmt.Errorf("lifecycle phase ticks must be >= 0")
+	}
+	return nil
+}
+
+func (l MissionLifecycle) ticks(phase string) int {
+	switch phase {
+	case PhasePlanned:
+		return l.Planned
+	case PhaseEnRoute:
+		return l.EnRoute
+	case PhaseEngaged:
+		return l.Engaged
+	case PhaseReturning:
+		return l.Returning
+	}
+	return 0
+}
+
+// next returns the first phase after phase that lasts at least one tick,
+// or PhaseClosed. An empty phase starts from the beginning.
+func (l MissionLifecycle) next(phase string) string {
+	started := phase == ""
+	for _, p := range phaseOrder {
+		if started && l.ticks(p) > 0 {
+			return p
+		}
+		if p == phase {
+			started = true
+		}
+	}
+	return PhaseClosed
+}
+
+// PhaseChange records a mission entering a phase.
+type PhaseChange struct {
+	Phase string `json:"phase"`
+	At    string `json:"at"`
+	Tick  int    `json:"tick"`
+}
+
+// InFlight reports whether the mission is still between dispatch and
+// closure; its outstanding units stay locked on the supplying nodes.
+func (m Mission) InFlight() bool {
+	return m.Phase != "" && m.Phase != PhaseClosed
+}
+
+// ActiveMissions returns the missions currently in flight.
+func ActiveMissions(st State) []Mission {
+	var out []Mission
+	for _, m := range st.Missions {
+		if m.InFlight() {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// lockedUnits maps node name to the units held by in-flight missions.
+func lockedUnits(st State) map[string]int {
+	locked := map[string]int{}
+	for _, m := range st.Missions {
+		if !m.InFlight() {
+			continue
+		}
+		for _, a := range m.Assignments {
+			if a.NetLoss > 0 {
+				locked[a.Node] += a.NetLoss
+			}
+		}
+	}
+	return locked
+}
+
+// lockedByOthers is lockedUnits without the units m itself holds.
+func lockedByOthers(st State, m Mission) map[string]int {
+	locked := lockedUnits(st)
+	if m.InFlight() {
+		for _, a := range m.Assignments {
+			if a.NetLoss > 0 {
+				locked[a.Node] -= a.NetLoss
+			}
+		}
+	}
+	return locked
+}
+
+// enterPhase moves m into phase at the current tick.
+func enterPhase(m *Mission, phase string, tick int, at string) {
+	m.Phase = phase
+	m.PhaseUntil = 0
+	if m.Lifecycle != nil && phase != PhaseClosed {
+		m.PhaseUntil = tick + m.Lifecycle.ticks(phase)
+	}
+	m.Timeline = append(m.Timeline, PhaseChange{Phase: phase, At: at, Tick: tick})
+}
+
+// advanceMissions moves in-flight missions whose phase has run out into
+// the next phase. Leaving the engagement (or closing, when the lifecycle
+// has no engaged phase) applies threat dynamics; closing returns the
+// recovered units to the nodes that supplied them. It returns the indexes
+// of the missions that changed and the units returned per node.
+func advanceMissions(st *State, dyn ThreatDynamics, at string) ([]int, map[string]int) {
+	var changed []int
+	returned := map[string]int{}
+	for i := range st.Missions {
+		m := &st.Missions[i]
+		moved := false
+		for m.InFlight() && m.PhaseUntil <= st.Core.Ticks {
+			from := m.Phase
+			enterPhase(m, m.Lifecycle.next(from), st.Core.Ticks, at)
+			if from == PhaseEngaged || (m.Phase == PhaseClosed && m.Lifecycle.Engaged == 0) {
+				if idx := findTargetIndex(st.Targets, m.Target); idx >= 0 {
+					applyMissionThreat(&st.Targets[idx], *m, dyn, at)
+				}
+			}
+			if m.Phase == PhaseClosed {
+				budget := int(math.Round(float64(m.Consumed) * missionRiskModel(*m).RecoveryRate(m.Outcome)))
+				for node, units := range returnUnits(st, m, budget) {
+					returned[node] += units
+				}
+			}
+			moved = true
+		}
+		if moved {
+			changed = append(changed, i)
+		}
+	}
+	return changed, returned
+}
+
+// returnUnits gives up to budget of the mission's outstanding units back to
+// the supplying nodes in ledger order and updates the ledger and totals.
+// Units held by other in-flight missions are never returned.
+func returnUnits(st *State, m *Mission, budget int) map[string]int {
+	others := lockedByOthers(*st, *m)
+	returned := map[string]int{}
+	for i := range m.Assignments {
+		if budget == 0 {
+			break
+		}
+		a := &m.Assignments[i]
+		n := findNodeIndex(st.Nodes, a.Node)
+		if n < 0 || a.NetLoss <= 0 {
+			continue
+		}
+		units := max(0, min(budget, a.NetLoss, st.Nodes[n].Deployed-others[a.Node]))
+		st.Nodes[n].Deployed -= units
+		a.Recovered += units
+		a.NetLoss -= units
+		m.Recovered += units
+		m.NetLoss -= units
+		budget -= units
+		if units > 0 {
+			returned[a.Node] += units
+		}
+	}
+	return returned
+}
@@ -0,0 +1,247 @@
+package skynet
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testLifecycle = MissionLifecycle{Planned: 1, EnRoute: 1, Engaged: 1, Returning: 1}
+
+func TestMissionLifecycleNext(t *testing.T) {
+	l := MissionLifecycle{EnRoute: 2, Returning: 1}
+	if l.next("") != PhaseEnRoute || l.next(PhaseEnRoute) != PhaseReturning || l.next(PhaseReturning) != PhaseClosed {
+		t.Fatalf("unexpected phase order for %+v", l)
+	}
+	if (MissionLifecycle{}).Enabled() {
+		t.Fatal("zero lifecycle should be disabled")
+	}
+}
+
+func TestPhasedMissionLocksUnitsUntilClosed(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	mission, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if mission.Phase != PhasePlanned || mission.PhaseUntil != 2 || mission.Recovered != 0 || mission.NetLoss != 5 {
+		t.Fatalf("unexpected in-flight mission: %+v", mission)
+	}
+	// Two free units on beta are repaired by ticks; the locked ones are not.
+	st.Nodes[1].Deployed += 2
+
+	phases := []string{PhaseEnRoute, PhaseEngaged, PhaseReturning}
+	for _, want := range phases {
+		result, err := Tick(&st, 1, SimulationConfig{}, ThreatDynamics{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Missions) != 1 || result.Missions[0].Phase != want {
+			t.Fatalf("expected phase %s, got %+v", want, result.Missions)
+		}
+	}
+	if st.Nodes[0].Deployed != 4 || st.Nodes[1].Deployed != 1 {
+		t.Fatalf("locked units were repaired: %+v", st.Nodes)
+	}
+
+	result, err := Tick(&st, 1, SimulationConfig{}, ThreatDynamics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := st.Missions[0]
+	want := int(math.Round(5 * standardModel.RecoveryRate(closed.Outcome)))
+	if closed.Phase != PhaseClosed || closed.InFlight() || closed.Recovered != want || closed.NetLoss != 5-want {
+		t.Fatalf("unexpected closed mission: %+v", closed)
+	}
+	returned := 0
+	for _, units := range result.Returned {
+		returned += units
+	}
+	if returned != want || len(closed.Timeline) != 5 {
+		t.Fatalf("unexpected close: returned=%d timeline=%+v", returned, closed.Timeline)
+	}
+	if len(ActiveMissions(st)) != 0 {
+		t.Fatal("expected no active missions")
+	}
+}
+
+func TestConcurrentMissionsContendForCapacity(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	if _, err := DispatchWith(&st, "hq", 7, DispatchOptions{Lifecycle: testLifecycle}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	second, err := DispatchWith(&st, "hq", 4, DispatchOptions{Lifecycle: testLifecycle})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if second.Consumed != 0 || second.InFlight() {
+		t.Fatalf("expected second mission to fail for capacity: %+v", second)
+	}
+	third, err := DispatchWith(&st, "hq", 3, DispatchOptions{})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	// An instantaneous mission recovers only its own units.
+	if third.Recovered > third.Consumed || AvailableCapacity(st.Nodes) != third.Recovered {
+		t.Fatalf("instantaneous mission touched locked units: %+v nodes=%+v", third, st.Nodes)
+	}
+	if active := ActiveMissions(st); len(active) != 1 || lockedUnits(st)["alpha"]+lockedUnits(st)["beta"] != 7 {
+		t.Fatalf("unexpected active missions: %+v", active)
+	}
+}
+
+func TestAbortInFlightMissionClosesIt(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	mission, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	aborted, returned, err := AbortMission(&st, mission.ID)
+	if err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	if returned != 5 || aborted.Phase != PhaseClosed || aborted.Status != MissionAborted || AvailableCapacity(st.Nodes) != 10 {
+		t.Fatalf("unexpected abort: returned=%d %+v", returned, aborted)
+	}
+	if _, err := Tick(&st, 4, SimulationConfig{}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	if st.Missions[0].Recovered != 5 {
+		t.Fatalf("closed mission advanced after abort: %+v", st.Missions[0])
+	}
+}
+
+func TestPhasedMissionThreatAppliesAfterEngagement(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	dyn := ThreatDynamics{ReduceOnNeutralized: 1}
+	mission, err := DispatchWith(&st, "hq", 2, DispatchOptions{Lifecycle: testLifecycle, Threat: dyn})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if mission.Outcome != "NEUTRALIZED" || st.Targets[0].Threat != 3 {
+		t.Fatalf("threat changed before engagement: %+v", st.Targets[0])
+	}
+	if _, err := Tick(&st, 3, SimulationConfig{}, dyn); err != nil {
+		t.Fatal(err)
+	}
+	if st.Targets[0].Threat != 2 {
+		t.Fatalf("expected threat reduction after engagement, got %d", st.Targets[0].Threat)
+	}
+}
+
+func TestTickEventReplaysMissionPhases(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	if _, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	replayed := st.Clone()
+	before := len(st.PendingEvents())
+	if _, err := Tick(&st, 4, SimulationConfig{}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range st.PendingEvents()[before:] {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if replayed.Missions[0].Phase != PhaseClosed || replayed.Missions[0].Recovered != st.Missions[0].Recovered {
+		t.Fatalf("unexpected replayed mission: %+v", replayed.Missions[0])
+	}
+	for i, n := range replayed.Nodes {
+		if n.Deployed != st.Nodes[i].Deployed {
+			t.Fatalf("node %s deployed mismatch: %d vs %d", n.Name, n.Deployed, st.Nodes[i].Deployed)
+		}
+	}
+}
+
+func TestRotateMissionsKeepsInFlight(t *testing.T) {
+	archive := NewMissionArchive(filepath.Join(t.TempDir(), "state.json"))
+	st := NewState()
+	st.Missions = []Mission{
+		{ID: "M-1", Target: "alpha", Consumed: 2, NetLoss: 1, Outcome: "CONTAINED", CreatedAt: "2026-01-01T00:00:00Z"},
+		{ID: "M-2", Target: "alpha", Consumed: 3, NetLoss: 2, Outcome: "CONTAINED", CreatedAt: "2026-01-20T00:00:00Z", Phase: PhaseEnRoute},
+		{ID: "M-3", Target: "beta", Consumed: 1, NetLoss: 0, Outcome: "NEUTRALIZED", CreatedAt: "2026-02-01T00:00:00Z"},
+	}
+	moved, err := RotateMissions(&st, archive, RetentionPolicy{KeepMissions: 1}, time.Now())
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if moved != 1 || st.Missions[0].ID != "M-2" {
+		t.Fatalf("in-flight mission was archived: moved=%d hot=%+v", moved, st.Missions)
+	}
+}
+
+func TestLockedUnitsBlockForcedResizeAndRemove(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	if _, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	// alpha supplied 4 locked units, beta 1.
+	if _, err := RemoveNode(&st, "alpha", true); err == nil {
+		t.Fatal("expected forced remove to refuse locked units")
+	}
+	if _, err := ResizeNode(&st, "alpha", 2, true); err == nil {
+		t.Fatal("expected forced resize below locked units to fail")
+	}
+	if _, err := ResizeNode(&st, "beta", 1, true); err != nil {
+		t.Fatalf("resize down to the locked units: %v", err)
+	}
+	if report := Fsck(&st, false); len(report.Violations) != 0 {
+		t.Fatalf("unexpected violations: %+v", report.Violations)
+	}
+}
+
+func TestDispatchRecoveryClampsLockedUnits(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	if _, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	// Simulate a tree where the locked units exceed alpha's deployed units.
+	st.Nodes[0].Deployed = 1
+	mission, err := Dispatch(&st, "hq", 2)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	for _, a := range mission.Assignments {
+		if a.Recovered < 0 || a.Recovered > a.Consumed {
+			t.Fatalf("unexpected ledger entry %+v", a)
+		}
+	}
+	for _, n := range st.Nodes {
+		if n.Deployed < 0 {
+			t.Fatalf("negative deployed units on %+v", n)
+		}
+	}
+	if !hasCode(Fsck(&st, false), CodeNodeLockedUnits) {
+		t.Fatal("expected fsck to report locked units above deployed")
+	}
+}
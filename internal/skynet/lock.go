@@ -0,0 +1,78 @@
+package skynet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrLocked = errors.New("state is locked by another process")
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// staleLockAge is how long a lock file may sit untouched before it is taken
+// to be left behind by a process that died holding it.
+const staleLockAge = 10 * time.Minute
+
+// Lock is an advisory, process-level lock on a state file.
+type Lock struct {
+	path string
+	file *os.File
+}
+
+func acquireLock(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := tryLock(path)
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return nil, err
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func lockedError(path string) error {
+	holder := "unknown pid"
+	if pid, ok := lockOwner(path); ok {
+		holder = fmt.Sprintf("pid %d", pid)
+	}
+	return fmt.Errorf("%w (%s holds %s)", ErrLocked, holder, path)
+}
+
+// lockOwner returns the pid recorded in the lock file at path.
+func lockOwner(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, err == nil
+}
+
+// staleLock reports whether the lock file at path was left behind: the
+// process it names is gone, or it is older than staleLockAge.
+func staleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > staleLockAge {
+		return true
+	}
+	pid, ok := lockOwner(path)
+	return ok && pid != os.Getpid() && !processAlive(pid)
+}
+
+func writeLockOwner(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
@@ -0,0 +1,67 @@
+//go:build !unix
+
+package skynet
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLock falls back to an exclusive lock file on platforms without flock.
+// A process that dies while holding it leaves the file behind; the next
+// caller removes it once staleLock says its owner is gone.
+func tryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) && removeStaleLock(path) {
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, lockedError(path)
+		}
+		return nil, err
+	}
+	if err := writeLockOwner(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return &Lock{path: path, file: f}, nil
+}
+
+// removeStaleLock deletes a stale lock file, unless another process has
+// replaced it in the meantime.
+func removeStaleLock(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !staleLock(path) {
+		return false
+	}
+	if current, err := os.Stat(path); err != nil || !os.SameFile(info, current) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// processAlive reports whether a process with the given pid exists. Where
+// the platform cannot tell, the process is assumed alive and only the age
+// of the lock file decides.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	if rerr := os.Remove(l.path); err == nil {
+		err = rerr
+	}
+	l.file = nil
+	return err
+}
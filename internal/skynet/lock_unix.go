@@ -0,0 +1,50 @@
+//go:build unix
+
+package skynet
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func tryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, lockedError(path)
+		}
+		return nil, err
+	}
+	if err := writeLockOwner(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{path: path, file: f}, nil
+}
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Unlock releases the lock. The lock file itself is left in place so that
+// concurrent openers never race on a freshly recreated inode; the kernel
+// also drops the lock if the process exits without unlocking.
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.file.Truncate(0)
+	err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,389 @@
+package skynet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Manifest declares the desired nodes and targets of a fleet. Optional
+// fields left unset (nil) are not managed by apply.
+type Manifest struct {
+	Nodes   []ManifestNode   `json:"nodes"`
+	Targets []ManifestTarget `json:"targets"`
+}
+
+type ManifestNode struct {
+	Name     string            `json:"name"`
+	Capacity int               `json:"capacity"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Priority *int              `json:"priority,omitempty"`
+}
+
+type ManifestTarget struct {
+	Name   string `json:"name"`
+	Threat int    `json:"threat"`
+}
+
+// Apply plan operations.
+const (
+	ApplyCreate  = "create"
+	ApplyUpdate  = "update"
+	ApplyRemove  = "remove"
+	ApplyDrain   = "drain"
+	ApplyArchive = "archive"
+)
+
+// ApplyAction is one reconciliation step.
+type ApplyAction struct {
+	Op      string   `json:"op"`
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Changes []string `json:"changes,omitempty"`
+
+	node   ManifestNode
+	target ManifestTarget
+}
+
+type ApplyPlan struct {
+	Actions   []ApplyAction `json:"actions"`
+	Unchanged int           `json:"unchanged"`
+}
+
+func (p ApplyPlan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// ApplyOptions controls reconciliation.
+type ApplyOptions struct {
+	// Prune removes nodes and targets missing from the manifest. Nodes with
+	// deployed units are drained and targets referenced by missions are
+	// archived instead, unless Force is set.
+	Prune bool
+	// Force allows shrinking nodes below their deployed units and removing
+	// busy nodes or referenced targets outright.
+	Force bool
+}
+
+// ReadManifest loads a manifest from a JSON or CSV file. CSV files carry a
+// header row with the columns kind,name,capacity,threat,labels,priority.
+func ReadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := ParseManifest(data, strings.ToLower(filepath.Ext(path)) == ".csv")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseManifest decodes a manifest. JSON is detected by a leading '{'
+// unless csvForm is set.
+func ParseManifest(data []byte, csvForm bool) (Manifest, error) {
+	var m Manifest
+	if !csvForm && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return Manifest{}, err
+		}
+	} else {
+		var err error
+		if m, err = parseManifestCSV(data); err != nil {
+			return Manifest{}, err
+		}
+	}
+	return m, m.validate()
+}
+
+func parseManifestCSV(data []byte) (Manifest, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("read CSV header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"kind", "name"} {
+		if _, ok := cols[required]; !ok {
+			return Manifest{}, fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+
+	var m Manifest
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return Manifest{}, err
+		}
+		line, _ := r.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		number := func(name string) (int, error) {
+			raw := field(name)
+			if raw == "" {
+				return 0, nil
+			}
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s %q", line, name, raw)
+			}
+			return n, nil
+		}
+		switch kind := strings.ToLower(field("kind")); kind {
+		case "node":
+			node := ManifestNode{Name: field("name")}
+			if node.Capacity, err = number("capacity"); err != nil {
+				return Manifest{}, err
+			}
+			if raw := field("labels"); raw != "" {
+				if node.Labels, err = ParseLabels(raw); err != nil {
+					return Manifest{}, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			if field("priority") != "" {
+				p, err := number("priority")
+				if err != nil {
+					return Manifest{}, err
+				}
+				node.Priority = &p
+			}
+			m.Nodes = append(m.Nodes, node)
+		case "target":
+			target := ManifestTarget{Name: field("name")}
+			if target.Threat, err = number("threat"); err != nil {
+				return Manifest{}, err
+			}
+			m.Targets = append(m.Targets, target)
+		default:
+			return Manifest{}, fmt.Errorf("line %d: unknown kind %q (use node or target)", line, kind)
+		}
+	}
+}
+
+func (m *Manifest) validate() error {
+	seen := map[string]bool{}
+	for i := range m.Nodes {
+		n := &m.Nodes[i]
+		n.Name = strings.TrimSpace(n.Name)
+		if n.Name == "" {
+			return fmt.Errorf("node #%d: name is required", i+1)
+		}
+		if seen[strings.ToLower(n.Name)] {
+			return fmt.Errorf("node %q is declared twice", n.Name)
+		}
+		seen[strings.ToLower(n.Name)] = true
+		if n.Capacity < 1 {
+			return fmt.Errorf("node %q: capacity must be >= 1", n.Name)
+		}
+		if n.Priority != nil && *n.Priority < 0 {
+			return fmt.Errorf("node %q: priority must be >= 0", n.Name)
+		}
+		if n.Labels != nil {
+			labels, err := ParseLabels(FormatLabels(n.Labels))
+			if err != nil {
+				return fmt.Errorf("node %q: %w", n.Name, err)
+			}
+			n.Labels = labels
+		}
+	}
+	seen = map[string]bool{}
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		t.Name = strings.TrimSpace(t.Name)
+		if t.Name == "" {
+			return fmt.Errorf("target #%d: name is required", i+1)
+		}
+		if seen[strings.ToLower(t.Name)] {
+			return fmt.Errorf("target %q is declared twice", t.Name)
+		}
+		seen[strings.ToLower(t.Name)] = true
+		if t.Threat < 1 || t.Threat > 10 {
+			return fmt.Errorf("target %q: threat must be between 1 and 10", t.Name)
+		}
+	}
+	return nil
+}
+
+// PlanApply computes the actions that reconcile st with the manifest
+// without modifying st. It fails if an action could not be carried out.
+func PlanApply(st State, m Manifest, opts ApplyOptions) (ApplyPlan, error) {
+	var plan ApplyPlan
+	declared := map[string]bool{}
+	locked := lockedUnits(st)
+	for _, n := range m.Nodes {
+		declared[strings.ToLower(n.Name)] = true
+		idx := findNodeIndex(st.Nodes, n.Name)
+		if idx < 0 {
+			plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyCreate, Kind: "node", Name: n.Name, Changes: nodeSpecChanges(Node{}, n, true), node: n})
+			continue
+		}
+		current := st.Nodes[idx]
+		if units := locked[current.Name]; n.Capacity < units {
+			return ApplyPlan{}, fmt.Errorf("node %q has %d unit(s) locked by in-flight missions: capacity %d would drop below them (recall or abort them first)", current.Name, units, n.Capacity)
+		}
+		if n.Capacity < current.Deployed && !opts.Force {
+			return ApplyPlan{}, fmt.Errorf("node %q has %d deployed unit(s): capacity %d would drop below them (use -force to write them off)", current.Name, current.Deployed, n.Capacity)
+		}
+		if changes := nodeSpecChanges(current, n, false); len(changes) > 0 {
+			plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyUpdate, Kind: "node", Name: current.Name, Changes: changes, node: n})
+		} else {
+			plan.Unchanged++
+		}
+	}
+	for _, t := range m.Targets {
+		declared["target:"+strings.ToLower(t.Name)] = true
+		idx := findTargetIndex(st.Targets, t.Name)
+		if idx < 0 {
+			plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyCreate, Kind: "target", Name: t.Name, Changes: []string{fmt.Sprintf("threat=%d", t.Threat)}, target: t})
+			continue
+		}
+		current := st.Targets[idx]
+		var changes []string
+		if current.Threat != t.Threat {
+			changes = append(changes, fmt.Sprintf("threat=%d->%d", current.Threat, t.Threat))
+		}
+		if current.Archived {
+			changes = append(changes, "status=archived->active")
+		}
+		if len(changes) > 0 {
+			plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyUpdate, Kind: "target", Name: current.Name, Changes: changes, target: t})
+		} else {
+			plan.Unchanged++
+		}
+	}
+
+	if opts.Prune {
+		for _, n := range st.Nodes {
+			if declared[strings.ToLower(n.Name)] {
+				continue
+			}
+			switch {
+			case locked[n.Name] > 0 && opts.Force:
+				return ApplyPlan{}, fmt.Errorf("node %q has %d unit(s) locked by in-flight missions: recall or abort them first", n.Name, locked[n.Name])
+			case n.Deployed == 0 || opts.Force:
+				plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyRemove, Kind: "node", Name: n.Name})
+			case !n.Draining:
+				plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyDrain, Kind: "node", Name: n.Name, Changes: []string{fmt.Sprintf("deployed=%d", n.Deployed)}})
+			}
+		}
+		for _, t := range st.Targets {
+			if declared["target:"+strings.ToLower(t.Name)] {
+				continue
+			}
+			switch {
+			case len(TargetMissions(st, t.Name)) == 0 || opts.Force:
+				plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyRemove, Kind: "target", Name: t.Name})
+			case !t.Archived:
+				plan.Actions = append(plan.Actions, ApplyAction{Op: ApplyArchive, Kind: "target", Name: t.Name, Changes: []string{"referenced by missions"}})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Apply reconciles st with the manifest and returns the executed plan.
+// On error st may be partially modified; callers should not save it.
+func Apply(st *State, m Manifest, opts ApplyOptions) (ApplyPlan, error) {
+	if !st.Core.Online {
+		return ApplyPlan{}, fmt.Errorf("core is offline: run awaken first")
+	}
+	plan, err := PlanApply(*st, m, opts)
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	for _, a := range plan.Actions {
+		if err := applyAction(st, a, opts); err != nil {
+			return ApplyPlan{}, fmt.Errorf("%s %s %q: %w", a.Op, a.Kind, a.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+func applyAction(st *State, a ApplyAction, opts ApplyOptions) error {
+	switch a.Kind + ":" + a.Op {
+	case "node:" + ApplyCreate:
+		if err := AddLabeledNode(st, a.node.Name, a.node.Capacity, a.node.Labels); err != nil {
+			return err
+		}
+		if a.node.Priority != nil && *a.node.Priority != 0 {
+			_, err := SetNodePriority(st, a.node.Name, *a.node.Priority)
+			return err
+		}
+	case "node:" + ApplyUpdate:
+		idx := findNodeIndex(st.Nodes, a.Name)
+		current := st.Nodes[idx]
+		if current.Capacity != a.node.Capacity {
+			if _, err := ResizeNode(st, a.Name, a.node.Capacity, opts.Force); err != nil {
+				return err
+			}
+		}
+		if a.node.Labels != nil && FormatLabels(current.Labels) != FormatLabels(a.node.Labels) {
+			var remove []string
+			for k := range current.Labels {
+				remove = append(remove, k)
+			}
+			if _, err := LabelNode(st, a.Name, a.node.Labels, remove); err != nil {
+				return err
+			}
+		}
+		if a.node.Priority != nil && current.Priority != *a.node.Priority {
+			if _, err := SetNodePriority(st, a.Name, *a.node.Priority); err != nil {
+				return err
+			}
+		}
+	case "node:" + ApplyRemove:
+		_, err := RemoveNode(st, a.Name, opts.Force)
+		return err
+	case "node:" + ApplyDrain:
+		_, err := DrainNode(st, a.Name, true)
+		return err
+	case "target:" + ApplyCreate, "target:" + ApplyUpdate:
+		return AddTarget(st, a.target.Name, a.target.Threat)
+	case "target:" + ApplyRemove:
+		_, err := RemoveTarget(st, a.Name, opts.Force)
+		return err
+	case "target:" + ApplyArchive:
+		_, err := ArchiveTarget(st, a.Name, true)
+		return err
+	default:
+		return fmt.Errorf("unsupported action")
+	}
+	return nil
+}
+
+func nodeSpecChanges(current Node, n ManifestNode, create bool) []string {
+	var changes []string
+	if create {
+		changes = append(changes, fmt.Sprintf("capacity=%d", n.Capacity))
+	} else if current.Capacity != n.Capacity {
+		changes = append(changes, fmt.Sprintf("capacity=%d->%d", current.Capacity, n.Capacity))
+	}
+	if n.Labels != nil && FormatLabels(current.Labels) != FormatLabels(n.Labels) {
+		changes = append(changes, fmt.Sprintf("labels=%q", FormatLabels(n.Labels)))
+	}
+	if n.Priority != nil && current.Priority != *n.Priority {
+		changes = append(changes, fmt.Sprintf("priority=%d", *n.Priority))
+	}
+	return changes
+}
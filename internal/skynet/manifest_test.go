@@ -0,0 +1,137 @@
+package skynet
+
+import "testing"
+
+const fleetCSV = `kind,name,capacity,threat,labels,priority
+# comment rows are ignored
+node,alpha,6,,"region=east,role=air",2
+node,beta,10,,,
+target,hq,,8,,
+`
+
+func TestParseManifestCSV(t *testing.T) {
+	m, err := ParseManifest([]byte(fleetCSV), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Nodes) != 2 || len(m.Targets) != 1 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if m.Nodes[0].Labels["role"] != "air" || m.Nodes[0].Priority == nil || *m.Nodes[0].Priority != 2 {
+		t.Fatalf("unexpected node: %+v", m.Nodes[0])
+	}
+	if m.Nodes[1].Labels != nil || m.Nodes[1].Priority != nil {
+		t.Fatalf("empty columns should leave fields unmanaged: %+v", m.Nodes[1])
+	}
+	if _, err := ParseManifest([]byte(`{"nodes": [{"name": "a", "capacity": 1, "cap": 2}]}`), false); err == nil {
+		t.Fatal("expected unknown JSON field to be rejected")
+	}
+	if _, err := ParseManifest([]byte("kind,name\nnode,a\nnode,A\n"), true); err == nil {
+		t.Fatal("expected invalid manifest to be rejected")
+	}
+}
+
+func TestApplyReconcilesAndConverges(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "old", Capacity: 3}},
+		Targets: []Target{{Name: "legacy", Threat: 3}},
+	})
+	m, _ := ParseManifest([]byte(fleetCSV), true)
+	plan, err := Apply(&st, m, ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 5 {
+		t.Fatalf("unexpected plan: %+v", plan.Actions)
+	}
+	if len(st.Nodes) != 2 || st.Nodes[0].Capacity != 6 || st.Nodes[0].Priority != 2 || st.Nodes[1].Name != "beta" {
+		t.Fatalf("unexpected nodes: %+v", st.Nodes)
+	}
+	if len(st.Targets) != 1 || st.Targets[0].Name != "hq" {
+		t.Fatalf("unexpected targets: %+v", st.Targets)
+	}
+
+	again, err := PlanApply(st, m, ApplyOptions{Prune: true})
+	if err != nil || !again.Empty() || again.Unchanged != 3 {
+		t.Fatalf("expected converged plan, got %+v err=%v", again, err)
+	}
+}
+
+func TestApplyPruneSoftensBusyResources(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "old", Capacity: 3}},
+		Targets: []Target{{Name: "legacy", Threat: 3}},
+	})
+	if _, err := Dispatch(&st, "legacy", 7); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := ParseManifest([]byte(`{"nodes": [], "targets": []}`), false)
+	plan, err := PlanApply(st, m, ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := map[string]string{}
+	for _, a := range plan.Actions {
+		ops[a.Name] = a.Op
+	}
+	if ops["legacy"] != ApplyArchive {
+		t.Fatalf("referenced target should be archived: %+v", plan.Actions)
+	}
+	for _, n := range st.Nodes {
+		if n.Deployed > 0 && ops[n.Name] != ApplyDrain {
+			t.Fatalf("busy node %s should be drained: %+v", n.Name, plan.Actions)
+		}
+	}
+}
+
+func TestPlanApplyRefusesShrinkBelowDeployed(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "old", Capacity: 3}},
+		Targets: []Target{{Name: "legacy", Threat: 3}},
+	})
+	st.Nodes[0].Deployed = 3
+	m, _ := ParseManifest([]byte(`{"nodes": [{"name": "alpha", "capacity": 2}]}`), false)
+	if _, err := PlanApply(st, m, ApplyOptions{}); err == nil {
+		t.Fatal("expected refusal")
+	}
+	if _, err := PlanApply(st, m, ApplyOptions{Force: true}); err != nil {
+		t.Fatalf("force should allow shrink: %v", err)
+	}
+}
+
+func TestPlanApplyRespectsInFlightMissions(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "old", Capacity: 3}},
+		Targets: []Target{{Name: "legacy", Threat: 3}},
+	})
+	if _, err := DispatchWith(&st, "legacy", 7, DispatchOptions{Lifecycle: MissionLifecycle{EnRoute: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	locked := lockedUnits(st)
+	if locked["alpha"] == 0 || locked["old"] == 0 {
+		t.Fatalf("fixture should lock units on both nodes: %v", locked)
+	}
+	shrink, _ := ParseManifest([]byte(`{"nodes": [{"name": "alpha", "capacity": 1}, {"name": "old", "capacity": 3}]}`), false)
+	prune, _ := ParseManifest([]byte(`{"nodes": [{"name": "alpha", "capacity": 4}]}`), false)
+	for name, m := range map[string]Manifest{"shrink": shrink, "prune": prune} {
+		opts := ApplyOptions{Prune: true, Force: true}
+		if _, err := PlanApply(st, m, opts); err == nil {
+			t.Fatalf("%s: plan should refuse locked units", name)
+		}
+		scratch := st.Clone()
+		if _, err := Apply(&scratch, m, opts); err == nil {
+			t.Fatalf("%s: apply should refuse locked units", name)
+		}
+	}
+	plan, err := PlanApply(st, prune, ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Actions[0].Name != "old" || plan.Actions[0].Op != ApplyDrain {
+		t.Fatalf("busy node should be drained without -force: %+v", plan.Actions)
+	}
+	scratch := st.Clone()
+	if _, err := Apply(&scratch, prune, ApplyOptions{Prune: true}); err != nil {
+		t.Fatalf("apply should carry out the planned drain: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package skynet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CurrentSchemaVersion is the schema version written by Store.Save.
+const CurrentSchemaVersion = 2
+
+// Migration upgrades a raw state document from schema version From to
+// From+1. Apply edits the decoded JSON object in place.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]any) error
+}
+
+type MigrationStep struct {
+	From        int    `json:"from"`
+	To          int    `json:"to"`
+	Description string `json:"description"`
+}
+
+type MigrationPlan struct {
+	Path        string          `json:"path"`
+	Exists      bool            `json:"exists"`
+	FromVersion int             `json:"from_version"`
+	ToVersion   int             `json:"to_version"`
+	Steps       []MigrationStep `json:"steps"`
+	Changes     []string        `json:"changes"`
+}
+
+func (p MigrationPlan) NeedsMigration() bool {
+	return len(p.Steps) > 0
+}
+
+var migrations = map[int]Migration{}
+
+// RegisterMigration adds a step to the migration registry. Each source
+// version may only be registered once.
+func RegisterMigration(m Migration) {
+	if _, exists := migrations[m.From]; exists {
+		panic(fmt.Sprintf("skynet: duplicate migration from schema v%d", m.From))
+	}
+	migrations[m.From] = m
+}
+
+func init() {
+	RegisterMigration(Migration{
+		From:        0,
+		Description: "stamp schema_version and normalize missing collections to empty lists",
+		Apply: func(doc map[string]any) error {
+			for _, key := range []string{"nodes", "targets", "missions"} {
+				if doc[key] == nil {
+					doc[key] = []any{}
+				}
+			}
+			return nil
+		},
+	})
+	RegisterMigration(Migration{
+		From:        1,
+		Description: "split target added_at into created_at/updated_at and seed threat_history",
+		Apply: func(doc map[string]any) error {
+			targets, _ := doc["targets"].([]any)
+			for _, raw := range targets {
+				t, ok := raw.(map[string]any)
+				if !ok {
+					continue
+				}
+				added, _ := t["added_at"].(string)
+				delete(t, "added_at")
+				if _, ok := t["created_at"]; !ok {
+					t["created_at"] = added
+				}
+				if _, ok := t["updated_at"]; !ok {
+					t["updated_at"] = added
+				}
+				if _, ok := t["threat_history"]; !ok {
+					t["threat_history"] = []any{map[string]any{"at": added, "threat": t["threat"], "reason": ThreatReasonRegistered}}
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// PlanMigration reports how the state file on disk would be upgraded by
+// Load without writing anything.
+func (s FileStore) PlanMigration() (MigrationPlan, error) {
+	return planFileMigration(s.Path, s.Key)
+}
+
+func planFileMigration(path string, key *StateKey) (MigrationPlan, error) {
+	plan := MigrationPlan{Path: path, FromVersion: CurrentSchemaVersion, ToVersion: CurrentSchemaVersion}
+	data, err := readSealedFile(path, key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return plan, nil
+		}
+		return MigrationPlan{}, err
+	}
+	return planMigrationData(path, data)
+}
+
+func planMigrationData(path string, data []byte) (MigrationPlan, error) {
+	plan := MigrationPlan{Path: path, Exists: true, ToVersion: CurrentSchemaVersion}
+
+	var before map[string]any
+	if err := json.Unmarshal(data, &before); err != nil {
+		return MigrationPlan{}, err
+	}
+	after, steps, from, err := migrateDocument(data)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	plan.FromVersion = from
+	plan.Steps = steps
+	plan.Changes = jsonChanges("", before, after)
+	return plan, nil
+}
+
+func decodeState(data []byte) (State, error) {
+	doc, _, _, err := migrateDocument(data)
+	if err != nil {
+		return State{}, err
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return State{}, err
+	}
+	var st State
+	if err := json.Unmarshal(migrated, &st); err != nil {
+		return State{}, err
+	}
+	return st, nil
+}
+
+func migrateDocument(data []byte) (map[string]any, []MigrationStep, int, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, 0, err
+	}
+	if doc == nil {
+		return nil, nil, 0, fmt.Errorf("state document is not a JSON object")
+	}
+
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		v, ok := raw.(float64)
+		if !ok || v != float64(int(v)) || v < 0 {
+			return nil, nil, 0, fmt.Errorf("invalid schema_version %v", raw)
+		}
+		version = int(v)
+	}
+	if version > CurrentSchemaVersion {
+		return nil, nil, 0, fmt.Errorf("state schema v%d is newer than supported v%d: upgrade skynet", version, CurrentSchemaVersion)
+	}
+
+	from := version
+	steps := []MigrationStep{}
+	for ; version < CurrentSchemaVersion; version++ {
+		m, ok := migrations[version]
+		if !ok {
+			return nil, nil, 0, fmt.Errorf("no migration registered from schema v%d", version)
+		}
+		if err := m.Apply(doc); err != nil {
+			return nil, nil, 0, fmt.Errorf("migrate schema v%d -> v%d: %w", version, version+1, err)
+		}
+		doc["schema_version"] = float64(version + 1)
+		steps = append(steps, MigrationStep{From: version, To: version + 1, Description: m.Description})
+	}
+	return doc, steps, from, nil
+}
+
+// jsonChanges lists added, removed and changed paths between two decoded
+// JSON values.
+func jsonChanges(path string, before, after any) []string {
+	changes := []string{}
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(b)+len(a))
+		for k := range b {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, seen := b[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+			switch {
+			case !inBefore:
+				changes = append(changes, fmt.Sprintf("+ %s = %s", child, compactJSON(av)))
+			case !inAfter:
+				changes = append(changes, fmt.Sprintf("- %s", child))
+			default:
+				changes = append(changes, jsonChanges(child, bv, av)...)
+			}
+		}
+		return changes
+	case []any:
+		a, ok := after.([]any)
+		if !ok || len(a) != len(b) {
+			break
+		}
+		for i := range b {
+			changes = append(changes, jsonChanges(fmt.Sprintf("%s[%d]", path, i), b[i], a[i])...)
+		}
+		return changes
+	}
+	if compactJSON(before) != compactJSON(after) {
+		changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", path, compactJSON(before), compactJSON(after)))
+	}
+	return changes
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,87 @@
+package skynet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const legacyStateV0 = `{
+  "core": {"online": true, "mode": "defense", "version": "T-800.1"},
+  "nodes": [{"name": "alpha", "capacity": 5, "deployed": 1}],
+  "targets": null,
+  "missions": null
+}`
+
+func TestStoreLoadMigratesLegacyState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(legacyStateV0), 0o644); err != nil {
+		t.Fatalf("write legacy state: %v", err)
+	}
+
+	st, err := NewStore(path).Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if st.SchemaVersion != CurrentSchemaVersion {
+		t.Fatalf("expected schema v%d, got v%d", CurrentSchemaVersion, st.SchemaVersion)
+	}
+	if st.Targets == nil || st.Missions == nil {
+		t.Fatal("expected null collections to be normalized to empty lists")
+	}
+	if len(st.Nodes) != 1 || st.Nodes[0].Deployed != 1 {
+		t.Fatalf("unexpected nodes after migration: %+v", st.Nodes)
+	}
+}
+
+func TestPlanMigrationDoesNotWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(legacyStateV0), 0o644); err != nil {
+		t.Fatalf("write legacy state: %v", err)
+	}
+
+	plan, err := NewStore(path).PlanMigration()
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !plan.NeedsMigration() || plan.FromVersion != 0 || plan.ToVersion != CurrentSchemaVersion {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if len(plan.Changes) == 0 {
+		t.Fatal("expected plan to list changes")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != legacyStateV0 {
+		t.Fatal("plan must not modify the state file")
+	}
+}
+
+func TestStoreLoadRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"schema_version": 999}`), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if _, err := NewStore(path).Load(); err == nil {
+		t.Fatal("expected error for newer schema version")
+	}
+}
+
+func TestMigrationSplitsTargetTimestamps(t *testing.T) {
+	legacy := `{"schema_version": 1, "core": {"online": true}, "nodes": [], "missions": [],
+  "targets": [{"name": "hq", "threat": 7, "added_at": "2024-01-02T03:04:05Z"}]}`
+	st, err := decodeState([]byte(legacy))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	tg := st.Targets[0]
+	if tg.CreatedAt != "2024-01-02T03:04:05Z" || tg.UpdatedAt != tg.CreatedAt {
+		t.Fatalf("timestamps not migrated: %+v", tg)
+	}
+	if len(tg.ThreatHistory) != 1 || tg.ThreatHistory[0].Threat != 7 {
+		t.Fatalf("threat history not seeded: %+v", tg.ThreatHistory)
+	}
+}
@@ -0,0 +1,134 @@
+package skynet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupMission finds a mission in st by ID or by a unique ID prefix.
+func LookupMission(st State, id string) (Mission, error) {
+	idx, err := missionIndex(st, id)
+	if err != nil {
+		return Mission{}, err
+	}
+	return st.Missions[idx], nil
+}
+
+// Mission statuses set by recall and abort.
+const (
+	MissionRecalled = "recalled"
+	MissionAborted  = "aborted"
+)
+
+// MissionAction records a command applied to a mission after dispatch.
+type MissionAction struct {
+	Action string `json:"action"`
+	At     string `json:"at"`
+	Units  int    `json:"units"`
+}
+
+// RecallMission returns a mission's outstanding units (supplied but not
+// yet recovered) to the nodes that supplied them, crediting them to
+// Recovered.
+func RecallMission(st *State, id string) (Mission, int, error) {
+	return settleMission(st, id, MissionRecalled)
+}
+
+// AbortMission calls off an in-flight mission: its outstanding units come
+// home as with recall, the mission is closed where it stands, and it no
+// longer counts as a success. Missions settled at dispatch cannot be
+// aborted; recall their outstanding units instead.
+func AbortMission(st *State, id string) (Mission, int, error) {
+	return settleMission(st, id, MissionAborted)
+}
+
+func settleMission(st *State, id, status string) (Mission, int, error) {
+	idx, err := missionIndex(*st, id)
+	if err != nil {
+		return Mission{}, 0, err
+	}
+	m := &st.Missions[idx]
+	if m.Status == MissionAborted {
+		return Mission{}, 0, fmt.Errorf("mission %s was already aborted", m.ID)
+	}
+	if status == MissionAborted && !m.InFlight() {
+		if m.Phase == PhaseClosed {
+			return Mission{}, 0, fmt.Errorf("mission %s is not in flight: it closed already", m.ID)
+		}
+		return Mission{}, 0, fmt.Errorf("mission %s is not in flight: it settled at dispatch (use recall for its outstanding units)", m.ID)
+	}
+	if m.NetLoss > 0 && len(m.Assignments) == 0 {
+		return Mission{}, 0, fmt.Errorf("mission %s predates node ledgers: its units cannot be attributed to nodes", m.ID)
+	}
+	if status != MissionAborted && outstandingUnits(*st, *m) == 0 {
+		return Mission{}, 0, fmt.Errorf("mission %s has no outstanding units to recall", m.ID)
+	}
+
+	before := deployedByNode(st.Nodes)
+	returned := outstandingUnits(*st, *m)
+	returnUnits(st, m, returned)
+	at := st.timestamp()
+	m.Status = status
+	if m.InFlight() {
+		enterPhase(m, PhaseClosed, st.Core.Ticks, at)
+	}
+	m.Actions = append(m.Actions, MissionAction{Action: status, At: at, Units: returned})
+	st.record(EventMissionUpdated, at, MissionUpdatedEvent{Mission: *m, Deployed: deployedDelta(before, st.Nodes)})
+	return *m, returned, nil
+}
+
+// outstandingUnits counts ledger units still deployed on existing nodes and
+// not held by other in-flight missions.
+func outstandingUnits(st State, m Mission) int {
+	others := lockedByOthers(st, m)
+	total := 0
+	for _, a := range m.Assignments {
+		if n := findNodeIndex(st.Nodes, a.Node); n >= 0 && a.NetLoss > 0 {
+			total += max(0, min(a.NetLoss, st.Nodes[n].Deployed-others[a.Node]))
+		}
+	}
+	return total
+}
+
+// missionIndex resolves a mission reference: an exact ID (case-insensitive)
+// wins, otherwise the reference must be the prefix of exactly one ID.
+func missionIndex(st State, ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1, fmt.Errorf("mission ID is required")
+	}
+	if idx := findMissionIndex(st.Missions, ref); idx >= 0 {
+		return idx, nil
+	}
+	var matches []int
+	for i, m := range st.Missions {
+		if len(m.ID) > len(ref) && strings.EqualFold(m.ID[:len(ref)], ref) {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return -1, fmt.Errorf("mission %q not found", ref)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, 0, 3)
+	for _, idx := range matches[:min(len(matches), 3)] {
+		ids = append(ids, st.Missions[idx].ID)
+	}
+	more := ""
+	if len(matches) > len(ids) {
+		more = fmt.Sprintf(" and %d more", len(matches)-len(ids))
+	}
+	return -1, fmt.Errorf("mission reference %q is ambiguous: matches %s%s", ref, strings.Join(ids, ", "), more)
+}
+
+// findMissionIndex returns the index of the mission with exactly id, or -1.
+func findMissionIndex(missions []Mission, id string) int {
+	for i, m := range missions {
+		if strings.EqualFold(m.ID, id) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,183 @@
+package skynet
+
+import "testing"
+
+func TestLookupMission(t *testing.T) {
+	st := NewState()
+	st.Missions = []Mission{{ID: "M-1", Target: "hq"}, {ID: "M-2", Target: "depot"}}
+	m, err := LookupMission(st, "m-2")
+	if err != nil || m.Target != "depot" {
+		t.Fatalf("unexpected lookup: %+v err=%v", m, err)
+	}
+	if _, err := LookupMission(st, "M-3"); err == nil {
+		t.Fatal("expected missing mission error")
+	}
+}
+
+func TestRecallMissionReturnsUnitsToSuppliers(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+	dispatched, err := Dispatch(&st, "hq", 6)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	mission, returned, err := RecallMission(&st, dispatched.ID)
+	if err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	if returned != dispatched.NetLoss || mission.NetLoss != 0 || mission.Recovered != dispatched.Consumed {
+		t.Fatalf("unexpected recall totals: returned=%d %+v", returned, mission)
+	}
+	for _, n := range st.Nodes {
+		if n.Deployed != 0 {
+			t.Fatalf("node %s still has %d deployed", n.Name, n.Deployed)
+		}
+	}
+	for _, a := range mission.Assignments {
+		if a.NetLoss != 0 || a.Recovered != a.Consumed {
+			t.Fatalf("unexpected ledger after recall: %+v", a)
+		}
+	}
+	if mission.Status != MissionRecalled || len(mission.Actions) != 1 || mission.Actions[0].Units != returned {
+		t.Fatalf("unexpected recall record: %+v", mission)
+	}
+	if _, _, err := RecallMission(&st, dispatched.ID); err == nil {
+		t.Fatal("expected error recalling a settled mission")
+	}
+	if _, _, err := AbortMission(&st, dispatched.ID); err == nil {
+		t.Fatal("expected error aborting a mission that is not in flight")
+	}
+}
+
+func TestAbortMissionIsNotSuccessful(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	dispatched, err := DispatchWith(&st, "hq", 5, DispatchOptions{Lifecycle: testLifecycle})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if !isMissionSuccess(dispatched) {
+		t.Fatalf("fixture should succeed: %+v", dispatched)
+	}
+	mission, _, err := AbortMission(&st, dispatched.ID)
+	if err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	if mission.Status != MissionAborted || isMissionSuccess(mission) {
+		t.Fatalf("unexpected aborted mission: %+v", mission)
+	}
+	report, err := BuildMissionReport(st, 0)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if report.SuccessfulMissions != 0 || report.TotalNetLoss != 0 {
+		t.Fatalf("unexpected report after abort: %+v", report)
+	}
+}
+
+func TestAbortRequiresInFlightMission(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+	dispatched, err := Dispatch(&st, "hq", 6)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if _, _, err := AbortMission(&st, dispatched.ID); err == nil {
+		t.Fatal("expected error aborting a mission settled at dispatch")
+	}
+	if st.Missions[0].Status != "" || st.Missions[0].NetLoss != dispatched.NetLoss {
+		t.Fatalf("failed abort changed the mission: %+v", st.Missions[0])
+	}
+	if _, _, err := RecallMission(&st, dispatched.ID); err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+}
+
+func TestRecallSkipsRepairedAndLockedUnits(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+	first, err := Dispatch(&st, "hq", 4)
+	if err != nil || first.NetLoss == 0 {
+		t.Fatalf("fixture mission should leave a net loss: %+v err=%v", first, err)
+	}
+	rate := 10
+	if _, err := Tick(&st, 1, SimulationConfig{Start: "2030-01-01T00:00:00Z", RepairRate: &rate}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DispatchWith(&st, "hq", 6, DispatchOptions{Lifecycle: MissionLifecycle{EnRoute: 2}}); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if st.Nodes[0].Deployed != 6 {
+		t.Fatalf("expected only the in-flight units deployed, got %+v", st.Nodes[0])
+	}
+	// The first mission's units were repaired; the 6 deployed belong to
+	// the mission in flight.
+	if _, _, err := RecallMission(&st, first.ID); err == nil {
+		t.Fatal("expected recall to find no outstanding units")
+	}
+	if st.Nodes[0].Deployed != 6 || len(Fsck(&st, false).Violations) != 0 {
+		t.Fatalf("recall released locked units: %+v", st.Nodes[0])
+	}
+}
+
+func TestSettleMissionRequiresLedger(t *testing.T) {
+	st := NewState()
+	st.Nodes = []Node{{Name: "alpha", Capacity: 4, Deployed: 2}}
+	st.Missions = []Mission{{ID: "M-1", Consumed: 3, Recovered: 1, NetLoss: 2}}
+	if _, _, err := RecallMission(&st, "M-1"); err == nil {
+		t.Fatal("expected error for mission without a node ledger")
+	}
+}
+
+func TestMissionUpdatedEventReplays(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}, {Name: "beta", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 9}},
+	})
+	dispatched, err := Dispatch(&st, "hq", 6)
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	replayed := st.Clone()
+	before := len(st.PendingEvents())
+	if _, _, err := RecallMission(&st, dispatched.ID); err != nil {
+		t.Fatalf("recall: %v", err)
+	}
+	for _, ev := range st.PendingEvents()[before:] {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if replayed.Missions[0].Status != MissionRecalled || replayed.Missions[0].NetLoss != 0 {
+		t.Fatalf("unexpected replayed mission: %+v", replayed.Missions[0])
+	}
+	for i, n := range replayed.Nodes {
+		if n.Deployed != st.Nodes[i].Deployed {
+			t.Fatalf("node %s deployed mismatch: %d vs %d", n.Name, n.Deployed, st.Nodes[i].Deployed)
+		}
+	}
+}
+
+func TestLookupMissionByUniquePrefix(t *testing.T) {
+	st := NewState()
+	st.Missions = []Mission{{ID: "M-000011"}, {ID: "M-000012"}, {ID: "M-1700000000000000000"}}
+	if m, err := LookupMission(st, "m-0000"); err == nil {
+		t.Fatalf("expected ambiguous reference error, got %+v", m)
+	}
+	m, err := LookupMission(st, "M-17")
+	if err != nil || m.ID != "M-1700000000000000000" {
+		t.Fatalf("unexpected prefix lookup: %+v err=%v", m, err)
+	}
+	if m, err := LookupMission(st, "M-000012"); err != nil || m.ID != "M-000012" {
+		t.Fatalf("unexpected exact lookup: %+v err=%v", m, err)
+	}
+}
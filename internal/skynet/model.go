@@ -1,7 +1,5 @@
 package skynet
 
-import "time"
-
 const (
 	defaultMode    = "defense"
 	defaultVersion = "T-800.1"
@@ -13,19 +11,40 @@ type Core struct {
 	Version     string `json:"version"`
 	LastAwaken  string `json:"last_awaken"`
 	LastMission string `json:"last_mission"`
+	// SimTime and Ticks track the simulated clock; empty until the first tick.
+	SimTime string `json:"sim_time,omitempty"`
+	Ticks   int    `json:"ticks,omitempty"`
+	// MissionSeq is the sequence number of the last mission ID issued.
+	MissionSeq int `json:"mission_seq,omitempty"`
 }
 
 type Node struct {
-	Name     string `json:"name"`
-	Capacity int    `json:"capacity"`
-	Deployed int    `json:"deployed"`
-	JoinedAt string `json:"joined_at"`
+	Name     string            `json:"name"`
+	Capacity int               `json:"capacity"`
+	Deployed int               `json:"deployed"`
+	JoinedAt string            `json:"joined_at"`
+	Draining bool              `json:"draining,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+	// RepairRate overrides the configured units repaired per tick.
+	RepairRate *int `json:"repair_rate,omitempty"`
 }
 
 type Target struct {
-	Name    string `json:"name"`
-	Threat  int    `json:"threat"`
-	AddedAt string `json:"added_at"`
+	Name          string         `json:"name"`
+	Threat        int            `json:"threat"`
+	Archived      bool           `json:"archived,omitempty"`
+	CreatedAt     string         `json:"created_at"`
+	UpdatedAt     string         `json:"updated_at"`
+	LastEngagedAt string         `json:"last_engaged_at,omitempty"`
+	ThreatHistory []ThreatChange `json:"threat_history,omitempty"`
+}
+
+// ThreatChange is one entry in a target's threat history.
+type ThreatChange struct {
+	At     string `json:"at"`
+	Threat int    `json:"threat"`
+	Reason string `json:"reason,omitempty"`
 }
 
 type Mission struct {
@@ -38,17 +57,56 @@ type Mission struct {
 	RiskScore int    `json:"risk_score"`
 	Outcome   string `json:"outcome"`
 	CreatedAt string `json:"created_at"`
+	Selector  string `json:"selector,omitempty"`
+	Strategy  string `json:"strategy,omitempty"`
+	// Shortfall is the requested units a partial dispatch could not send.
+	Shortfall int `json:"shortfall,omitempty"`
+	// Status is set once the mission is recalled or aborted.
+	Status  string          `json:"status,omitempty"`
+	Actions []MissionAction `json:"actions,omitempty"`
+	// Phase is the lifecycle phase of a mission dispatched with a
+	// lifecycle; PhaseUntil is the tick at which the phase ends.
+	Phase      string            `json:"phase,omitempty"`
+	PhaseUntil int               `json:"phase_until,omitempty"`
+	Lifecycle  *MissionLifecycle `json:"lifecycle,omitempty"`
+	Timeline   []PhaseChange     `json:"timeline,omitempty"`
+	// Assignments is the per-node ledger of units supplied and recovered.
+	Assignments []NodeAssignment `json:"assignments,omitempty"`
+	// RiskModel and RiskParams identify the model that scored the mission.
+	RiskModel  string             `json:"risk_model,omitempty"`
+	RiskParams map[string]float64 `json:"risk_params,omitempty"`
+}
+
+// UnderStrength reports whether the mission went out with fewer units than
+// requested.
+func (m Mission) UnderStrength() bool {
+	return m.Shortfall > 0
+}
+
+// NodeAssignment records how one node took part in a mission.
+type NodeAssignment struct {
+	Node      string `json:"node"`
+	Consumed  int    `json:"consumed"`
+	Recovered int    `json:"recovered"`
+	NetLoss   int    `json:"net_loss"`
 }
 
 type State struct {
-	Core     Core      `json:"core"`
-	Nodes    []Node    `json:"nodes"`
-	Targets  []Target  `json:"targets"`
-	Missions []Mission `json:"missions"`
+	SchemaVersion int       `json:"schema_version"`
+	Core          Core      `json:"core"`
+	Nodes         []Node    `json:"nodes"`
+	Targets       []Target  `json:"targets"`
+	Missions      []Mission `json:"missions"`
+
+	// events holds mutations recorded since load, for journaling stores.
+	events []Event
+	// engine supplies time, IDs and randomness; nil means DefaultEngine.
+	engine *Engine
 }
 
 func NewState() State {
 	return State{
+		SchemaVersion: CurrentSchemaVersion,
 		Core: Core{
 			Online:      false,
 			Mode:        defaultMode,
@@ -62,6 +120,48 @@ func NewState() State {
 	}
 }
 
-func now() string {
-	return time.Now().UTC().Format(time.RFC3339)
+// Clone returns a copy of st that shares no mutable data with it apart
+// from the attached engine. Pending events are not copied.
+func (st State) Clone() State {
+	out := st
+	out.events = nil
+	out.Nodes = make([]Node, len(st.Nodes))
+	for i, n := range st.Nodes {
+		if n.Labels != nil {
+			labels := make(map[string]string, len(n.Labels))
+			for k, v := range n.Labels {
+				labels[k] = v
+			}
+			n.Labels = labels
+		}
+		if n.RepairRate != nil {
+			rate := *n.RepairRate
+			n.RepairRate = &rate
+		}
+		out.Nodes[i] = n
+	}
+	out.Targets = make([]Target, len(st.Targets))
+	for i, t := range st.Targets {
+		t.ThreatHistory = append([]ThreatChange(nil), t.ThreatHistory...)
+		out.Targets[i] = t
+	}
+	out.Missions = make([]Mission, len(st.Missions))
+	for i, m := range st.Missions {
+		m.Assignments = append([]NodeAssignment(nil), m.Assignments...)
+		m.Actions = append([]MissionAction(nil), m.Actions...)
+		if m.RiskParams != nil {
+			params := make(map[string]float64, len(m.RiskParams))
+			for k, v := range m.RiskParams {
+				params[k] = v
+			}
+			m.RiskParams = params
+		}
+		m.Timeline = append([]PhaseChange(nil), m.Timeline...)
+		if m.Lifecycle != nil {
+			lifecycle := *m.Lifecycle
+			m.Lifecycle = &lifecycle
+		}
+		out.Missions[i] = m
+	}
+	return out
 }
@@ -0,0 +1,155 @@
+package skynet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RemoveNode retires a node. A node with deployed units is only removed when
+// force is set; those units are written off with it. Units locked by
+// in-flight missions are never written off.
+func RemoveNode(st *State, name string, force bool) (Node, error) {
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return Node{}, err
+	}
+	node := st.Nodes[idx]
+	if locked := lockedUnits(*st)[node.Name]; locked > 0 {
+		return Node{}, fmt.Errorf("node %q has %d unit(s) locked by in-flight missions: recall or abort them first", node.Name, locked)
+	}
+	if node.Deployed > 0 && !force {
+		return Node{}, fmt.Errorf("node %q has %d deployed unit(s): drain it first or use -force", node.Name, node.Deployed)
+	}
+	st.Nodes = append(st.Nodes[:idx:idx], st.Nodes[idx+1:]...)
+	st.record(EventNodeRemoved, st.timestamp(), NodeRemovedEvent{Name: node.Name})
+	return node, nil
+}
+
+// DrainNode marks a node as draining: it supplies no new units and is
+// refilled first by recovery until its deployed units reach zero. Passing
+// drain=false returns the node to service.
+func DrainNode(st *State, name string, drain bool) (Node, error) {
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return Node{}, err
+	}
+	st.Nodes[idx].Draining = drain
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: st.Nodes[idx].Name, Node: st.Nodes[idx]})
+	return st.Nodes[idx], nil
+}
+
+// ResizeNode changes a node's capacity. Shrinking below the deployed units
+// is refused unless force is set, in which case the excess deployed units
+// are written off; their count is returned. Shrinking below the units
+// locked by in-flight missions is always refused.
+func ResizeNode(st *State, name string, capacity int, force bool) (int, error) {
+	if capacity < 1 {
+		return 0, fmt.Errorf("capacity must be >= 1")
+	}
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return 0, err
+	}
+	node := &st.Nodes[idx]
+	if locked := lockedUnits(*st)[node.Name]; capacity < locked {
+		return 0, fmt.Errorf("node %q has %d unit(s) locked by in-flight missions: capacity %d would drop below them (recall or abort them first)", node.Name, locked, capacity)
+	}
+	writtenOff := 0
+	if capacity < node.Deployed {
+		if !force {
+			return 0, fmt.Errorf("node %q has %d deployed unit(s): capacity %d would drop below them (use -force to write them off)", node.Name, node.Deployed, capacity)
+		}
+		writtenOff = node.Deployed - capacity
+		node.Deployed = capacity
+	}
+	node.Capacity = capacity
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: node.Name, Node: *node})
+	return writtenOff, nil
+}
+
+// RenameNode gives a node a new name, rejecting names already in use.
+func RenameNode(st *State, name, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("new node name is required")
+	}
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return err
+	}
+	if other := findNodeIndex(st.Nodes, newName); other >= 0 && other != idx {
+		return fmt.Errorf("node %q already exists", newName)
+	}
+	oldName := st.Nodes[idx].Name
+	st.Nodes[idx].Name = newName
+	renameLedgerNode(st, oldName, newName)
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: oldName, Node: st.Nodes[idx]})
+	return nil
+}
+
+// SetNodePriority sets the weight used by the weighted allocation strategy.
+func SetNodePriority(st *State, name string, priority int) (Node, error) {
+	if priority < 0 {
+		return Node{}, fmt.Errorf("priority must be >= 0")
+	}
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return Node{}, err
+	}
+	st.Nodes[idx].Priority = priority
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: st.Nodes[idx].Name, Node: st.Nodes[idx]})
+	return st.Nodes[idx], nil
+}
+
+// SetNodeRepairRate overrides the units a node repairs per tick; nil
+// restores the configured default.
+func SetNodeRepairRate(st *State, name string, rate *int) (Node, error) {
+	if rate != nil && *rate < 0 {
+		return Node{}, fmt.Errorf("repair rate must be >= 0")
+	}
+	idx, err := nodeIndex(st, name)
+	if err != nil {
+		return Node{}, err
+	}
+	st.Nodes[idx].RepairRate = rate
+	st.record(EventNodeUpdated, st.timestamp(), NodeUpdatedEvent{Name: st.Nodes[idx].Name, Node: st.Nodes[idx]})
+	return st.Nodes[idx], nil
+}
+
+// renameLedgerNode keeps mission ledgers in the hot state attributed to a
+// renamed node. Archived segments keep the name in use at the time.
+func renameLedgerNode(st *State, oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+	for i := range st.Missions {
+		for j := range st.Missions[i].Assignments {
+			if a := &st.Missions[i].Assignments[j]; a.Node == oldName {
+				a.Node = newName
+			}
+		}
+	}
+}
+
+// NodeStatus describes a node's lifecycle state for display.
+func NodeStatus(n Node) string {
+	switch {
+	case n.Draining && n.Deployed > 0:
+		return "draining"
+	case n.Draining:
+		return "drained"
+	}
+	return "active"
+}
+
+func nodeIndex(st *State, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return -1, fmt.Errorf("node name is required")
+	}
+	idx := findNodeIndex(st.Nodes, name)
+	if idx < 0 {
+		return -1, fmt.Errorf("node %q not found", name)
+	}
+	return idx, nil
+}
@@ -0,0 +1,99 @@
+package skynet
+
+import "testing"
+
+func TestRemoveNodeRequiresForceWithDeployedUnits(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Deployed: 3}, {Name: "beta", Capacity: 5}},
+	})
+	if _, err := RemoveNode(&st, "ALPHA", false); err == nil {
+		t.Fatal("expected refusal for node with deployed units")
+	}
+	node, err := RemoveNode(&st, "alpha", true)
+	if err != nil {
+		t.Fatalf("forced remove failed: %v", err)
+	}
+	if node.Deployed != 3 || len(st.Nodes) != 1 || st.Nodes[0].Name != "beta" {
+		t.Fatalf("unexpected result: node=%+v nodes=%+v", node, st.Nodes)
+	}
+	if _, err := RemoveNode(&st, "beta", false); err != nil {
+		t.Fatalf("idle node should be removable: %v", err)
+	}
+}
+
+func TestDrainNodeBlocksConsumptionAndRefillsFirst(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Deployed: 3}, {Name: "beta", Capacity: 5}},
+	})
+	if _, err := DrainNode(&st, "alpha", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := AvailableCapacity(st.Nodes); got != 5 {
+		t.Fatalf("draining node must not count as available, got %d", got)
+	}
+	if got := consumeUnits(st.Nodes, 2); got != 2 || st.Nodes[0].Deployed != 3 || st.Nodes[1].Deployed != 2 {
+		t.Fatalf("consumption touched draining node: %+v", st.Nodes)
+	}
+	recoverUnits(st.Nodes, 3)
+	if st.Nodes[0].Deployed != 0 || st.Nodes[1].Deployed != 2 {
+		t.Fatalf("recovery should refill draining node first: %+v", st.Nodes)
+	}
+	if NodeStatus(st.Nodes[0]) != "drained" {
+		t.Fatalf("expected drained, got %s", NodeStatus(st.Nodes[0]))
+	}
+}
+
+func TestResizeNodeRefusesBelowDeployed(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Deployed: 3}, {Name: "beta", Capacity: 5}},
+	})
+	if _, err := ResizeNode(&st, "alpha", 2, false); err == nil {
+		t.Fatal("expected refusal when shrinking below deployed")
+	}
+	writtenOff, err := ResizeNode(&st, "alpha", 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writtenOff != 1 || st.Nodes[0].Capacity != 2 || st.Nodes[0].Deployed != 2 {
+		t.Fatalf("unexpected resize: written_off=%d node=%+v", writtenOff, st.Nodes[0])
+	}
+}
+
+func TestRenameNodeRejectsDuplicates(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Deployed: 3}, {Name: "beta", Capacity: 5}},
+	})
+	replayed := st.Clone()
+	setup := len(st.PendingEvents())
+	if err := RenameNode(&st, "alpha", "BETA"); err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+	if err := RenameNode(&st, "alpha", "gamma"); err != nil {
+		t.Fatal(err)
+	}
+	if st.Nodes[0].Name != "gamma" {
+		t.Fatalf("rename not applied: %+v", st.Nodes)
+	}
+
+	for _, ev := range st.PendingEvents()[setup:] {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if replayed.Nodes[0].Name != "gamma" {
+		t.Fatalf("replayed rename not applied: %+v", replayed.Nodes)
+	}
+}
+
+func TestRenameNodeUpdatesMissionLedger(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes: []Node{{Name: "alpha", Capacity: 5, Deployed: 3}, {Name: "beta", Capacity: 5}},
+	})
+	st.Missions = []Mission{{ID: "M-1", Assignments: []NodeAssignment{{Node: "alpha", Consumed: 2}}}}
+	if err := RenameNode(&st, "alpha", "gamma"); err != nil {
+		t.Fatal(err)
+	}
+	if st.Missions[0].Assignments[0].Node != "gamma" {
+		t.Fatalf("ledger not renamed: %+v", st.Missions[0].Assignments)
+	}
+}
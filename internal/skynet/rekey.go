@@ -0,0 +1,116 @@
+package skynet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type RekeyResult struct {
+	Files        int  `json:"files"`
+	JournalLines int  `json:"journal_lines"`
+	Encrypted    bool `json:"encrypted"`
+}
+
+// Rekey re-encrypts every state-bearing file of the workspace holding
+// statePath (state, journal, snapshot, history and mission archive) from
+// oldKey to newKey. A nil key means plaintext, so Rekey also turns
+// encryption on or off. All files are decrypted and staged before any is
+// replaced; a wrong oldKey leaves the workspace untouched.
+func Rekey(statePath string, oldKey, newKey *StateKey) (RekeyResult, error) {
+	dir := filepath.Dir(statePath)
+	files := []string{statePath, filepath.Join(dir, journalSnapshotFile)}
+	for _, pattern := range []string{
+		filepath.Join(dir, historyDir, "*.json"),
+		filepath.Join(dir, archiveDir, "missions-*.jsonl.gz"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return RekeyResult{}, err
+		}
+		files = append(files, matches...)
+	}
+
+	result := RekeyResult{Encrypted: newKey != nil}
+	staged := map[string]string{}
+	cleanup := func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}
+	stage := func(path string, data []byte) error {
+		perm := os.FileMode(0o644)
+		if newKey != nil {
+			perm = 0o600
+		}
+		tmp := path + ".rekey"
+		if err := writeFileAtomic(tmp, data, perm); err != nil {
+			return err
+		}
+		staged[path] = tmp
+		return nil
+	}
+
+	for _, path := range files {
+		plain, err := readSealedFile(path, oldKey)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			cleanup()
+			return RekeyResult{}, err
+		}
+		sealed, err := newKey.seal(plain)
+		if err != nil {
+			cleanup()
+			return RekeyResult{}, err
+		}
+		if err := stage(path, sealed); err != nil {
+			cleanup()
+			return RekeyResult{}, err
+		}
+		result.Files++
+	}
+
+	journal := filepath.Join(dir, journalFile)
+	if data, err := os.ReadFile(journal); err == nil {
+		var out bytes.Buffer
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			plain, err := oldKey.open(line)
+			if err != nil {
+				cleanup()
+				return RekeyResult{}, fmt.Errorf("%s:%d: %w", journal, i+1, err)
+			}
+			sealed, err := newKey.seal(plain)
+			if err != nil {
+				cleanup()
+				return RekeyResult{}, err
+			}
+			out.Write(sealed)
+			out.WriteByte('\n')
+			result.JournalLines++
+		}
+		if err := stage(journal, out.Bytes()); err != nil {
+			cleanup()
+			return RekeyResult{}, err
+		}
+		result.Files++
+	} else if !errors.Is(err, os.ErrNotExist) {
+		cleanup()
+		return RekeyResult{}, err
+	}
+
+	for path, tmp := range staged {
+		if err := os.Rename(tmp, path); err != nil {
+			cleanup()
+			return RekeyResult{}, err
+		}
+		delete(staged, path)
+	}
+	return result, nil
+}
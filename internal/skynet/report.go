@@ -7,19 +7,36 @@ import (
 )
 
 type MissionReport struct {
-	TotalMissions      int     `json:"total_missions"`
-	AnalyzedMissions   int     `json:"analyzed_missions"`
-	SuccessfulMissions int     `json:"successful_missions"`
-	FailedMissions     int     `json:"failed_missions"`
-	SuccessRate        float64 `json:"success_rate"`
-	AverageRisk        float64 `json:"average_risk"`
-	AverageNetLoss     float64 `json:"average_net_loss"`
-	TotalConsumed      int     `json:"total_consumed"`
-	TotalRecovered     int     `json:"total_recovered"`
-	TotalNetLoss       int     `json:"total_net_loss"`
-	MostTargeted       string  `json:"most_targeted"`
-	MostTargetedCount  int     `json:"most_targeted_count"`
-	LastMissionAt      string  `json:"last_mission_at"`
+	TotalMissions      int `json:"total_missions"`
+	AnalyzedMissions   int `json:"analyzed_missions"`
+	SuccessfulMissions int `json:"successful_missions"`
+	FailedMissions     int `json:"failed_missions"`
+	UnderStrength      int `json:"under_strength_missions"`
+	TotalShortfall     int `json:"total_shortfall"`
+	// InFlight counts missions whose losses are not final yet.
+	InFlight          int     `json:"in_flight_missions"`
+	SuccessRate       float64 `json:"success_rate"`
+	AverageRisk       float64 `json:"average_risk"`
+	AverageNetLoss    float64 `json:"average_net_loss"`
+	TotalConsumed     int     `json:"total_consumed"`
+	TotalRecovered    int     `json:"total_recovered"`
+	TotalNetLoss      int     `json:"total_net_loss"`
+	MostTargeted      string  `json:"most_targeted"`
+	MostTargetedCount int     `json:"most_targeted_count"`
+	LastMissionAt     string  `json:"last_mission_at"`
+	// NodeLosses attributes net loss to nodes using mission ledgers.
+	// Missions recorded before ledgers existed count as unattributed.
+	NodeLosses          []NodeLoss `json:"node_losses"`
+	UnattributedNetLoss int        `json:"unattributed_net_loss"`
+}
+
+type NodeLoss struct {
+	Node      string  `json:"node"`
+	Missions  int     `json:"missions"`
+	Consumed  int     `json:"consumed"`
+	Recovered int     `json:"recovered"`
+	NetLoss   int     `json:"net_loss"`
+	Share     float64 `json:"share"`
 }
 
 func BuildMissionReport(st State, last int) (MissionReport, error) {
@@ -45,12 +62,34 @@ func BuildMissionReport(st State, last int) (MissionReport, error) {
 	}
 
 	targetCount := map[string]int{}
+	byNode := map[string]*NodeLoss{}
 	for _, m := range missions {
+		if len(m.Assignments) == 0 {
+			report.UnattributedNetLoss += m.NetLoss
+		}
+		for _, a := range m.Assignments {
+			nl := byNode[a.Node]
+			if nl == nil {
+				nl = &NodeLoss{Node: a.Node}
+				byNode[a.Node] = nl
+			}
+			nl.Missions++
+			nl.Consumed += a.Consumed
+			nl.Recovered += a.Recovered
+			nl.NetLoss += a.NetLoss
+		}
 		if isMissionSuccess(m) {
 			report.SuccessfulMissions++
 		} else {
 			report.FailedMissions++
 		}
+		if m.UnderStrength() {
+			report.UnderStrength++
+			report.TotalShortfall += m.Shortfall
+		}
+		if m.InFlight() {
+			report.InFlight++
+		}
 		report.TotalConsumed += m.Consumed
 		report.TotalRecovered += m.Recovered
 		report.TotalNetLoss += m.NetLoss
@@ -66,11 +105,29 @@ func BuildMissionReport(st State, last int) (MissionReport, error) {
 	}
 
 	report.MostTargeted, report.MostTargetedCount = findMostTargeted(targetCount)
+	report.NodeLosses = sortedNodeLosses(byNode, report.TotalNetLoss)
 	return report, nil
 }
 
+func sortedNodeLosses(byNode map[string]*NodeLoss, totalNetLoss int) []NodeLoss {
+	out := make([]NodeLoss, 0, len(byNode))
+	for _, nl := range byNode {
+		if totalNetLoss != 0 {
+			nl.Share = float64(nl.NetLoss) / float64(totalNetLoss)
+		}
+		out = append(out, *nl)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].NetLoss != out[j].NetLoss {
+			return out[i].NetLoss > out[j].NetLoss
+		}
+		return out[i].Node < out[j].Node
+	})
+	return out
+}
+
 func isMissionSuccess(m Mission) bool {
-	return !strings.HasPrefix(strings.ToUpper(m.Outcome), "FAILED")
+	return m.Status != MissionAborted && !strings.HasPrefix(strings.ToUpper(m.Outcome), "FAILED")
 }
 
 func findMostTargeted(targetCount map[string]int) (string, int) {
@@ -80,3 +80,31 @@ func TestBuildMissionReportInvalidLast(t *testing.T) {
 		t.Fatal("expected error for negative last")
 	}
 }
+
+func TestBuildMissionReportNodeLosses(t *testing.T) {
+	st := NewState()
+	st.Missions = []Mission{
+		{Target: "hq", Consumed: 4, Recovered: 1, NetLoss: 3, Outcome: "CONTAINED", Assignments: []NodeAssignment{
+			{Node: "alpha", Consumed: 3, Recovered: 1, NetLoss: 2},
+			{Node: "beta", Consumed: 1, NetLoss: 1},
+		}},
+		{Target: "hq", Consumed: 2, Recovered: 0, NetLoss: 2, Outcome: "CONTAINED", Assignments: []NodeAssignment{
+			{Node: "beta", Consumed: 2, NetLoss: 2},
+		}},
+		{Target: "hq", Consumed: 1, Recovered: 0, NetLoss: 1, Outcome: "CONTAINED"},
+	}
+
+	report, err := BuildMissionReport(st, 0)
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	if len(report.NodeLosses) != 2 || report.NodeLosses[0].Node != "beta" || report.NodeLosses[0].NetLoss != 3 || report.NodeLosses[0].Missions != 2 {
+		t.Fatalf("unexpected node losses: %+v", report.NodeLosses)
+	}
+	if math.Abs(report.NodeLosses[1].Share-2.0/6.0) > 1e-9 {
+		t.Fatalf("unexpected share: %+v", report.NodeLosses[1])
+	}
+	if report.UnattributedNetLoss != 1 {
+		t.Fatalf("expected unattributed=1, got %d", report.UnattributedNetLoss)
+	}
+}
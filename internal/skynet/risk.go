@@ -0,0 +1,213 @@
+package skynet
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultRiskModel is the risk model used when none is configured.
+const DefaultRiskModel = "standard"
+
+// RiskModel scores a dispatch, turns the score into an outcome and decides
+// how much of the consumed force comes back for that outcome.
+type RiskModel interface {
+	Name() string
+	// Params returns the model's effective parameters. Passing them back
+	// to the model's factory must rebuild an equivalent model, since
+	// missions carry them to settle later.
+	Params() map[string]float64
+	Risk(threat, units, capacity int) int
+	Outcome(risk int, enoughCapacity bool) string
+	RecoveryRate(outcome string) float64
+}
+
+// RiskModelFactory builds a model from parameters; missing parameters take
+// the model's defaults.
+type RiskModelFactory func(params map[string]float64) (RiskModel, error)
+
+var (
+	riskModels       = map[string]RiskModelFactory{}
+	riskModelAliases = map[string]string{}
+)
+
+// RegisterRiskModel adds a model factory under name and any aliases. Names
+// may only be registered once.
+func RegisterRiskModel(name string, factory RiskModelFactory, aliases ...string) {
+	for _, alias := range append([]string{name}, aliases...) {
+		key := strings.ToLower(alias)
+		if _, exists := riskModelAliases[key]; exists {
+			panic(fmt.Sprintf("skynet: duplicate risk model %q", alias))
+		}
+		riskModelAliases[key] = name
+	}
+	riskModels[name] = factory
+}
+
+// NewRiskModel builds the named model with params; an empty name selects
+// the default.
+func NewRiskModel(name string, params map[string]float64) (RiskModel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultRiskModel
+	}
+	canonical, ok := riskModelAliases[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown risk model %q (available: %s)", name, strings.Join(RiskModelNames(), ", "))
+	}
+	return riskModels[canonical](params)
+}
+
+// RiskModelNames lists the registered model names.
+func RiskModelNames() []string {
+	names := make([]string, 0, len(riskModels))
+	for name := range riskModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RiskConfig selects the risk model for dispatch in config.json.
+type RiskConfig struct {
+	Model  string             `json:"model,omitempty"`
+	Params map[string]float64 `json:"params,omitempty"`
+}
+
+// Build returns the configured model.
+func (c RiskConfig) Build() (RiskModel, error) {
+	model, err := NewRiskModel(c.Model, c.Params)
+	if err != nil {
+		return nil, fmt.Errorf("risk: %w", err)
+	}
+	return model, nil
+}
+
+// standardRiskParams are the coefficients, thresholds and recovery rates
+// of the standard model.
+var standardRiskParams = map[string]float64{
+	"threat_weight":        1.2,
+	"units_weight":         0.6,
+	"capacity_weight":      -0.35,
+	"divisor":              2,
+	"contained_at":         5,
+	"extreme_at":           8,
+	"recovery_neutralized": 0.8,
+	"recovery_contained":   0.5,
+	"recovery_extreme":     0.2,
+}
+
+// parametricModel scores risk as the weighted sum of threat, units and
+// available capacity divided by divisor, rounded and clamped to 1..10.
+type parametricModel struct {
+	name   string
+	params map[string]float64
+}
+
+func newParametricModel(name string, params map[string]float64) (parametricModel, error) {
+	m := parametricModel{name: name, params: make(map[string]float64, len(standardRiskParams))}
+	for k, v := range standardRiskParams {
+		m.params[k] = v
+	}
+	for k, v := range params {
+		if _, ok := standardRiskParams[k]; !ok {
+			return parametricModel{}, fmt.Errorf("%s risk model: unknown parameter %q", name, k)
+		}
+		m.params[k] = v
+	}
+	p := m.params
+	if p["divisor"] <= 0 {
+		return parametricModel{}, fmt.Errorf("%s risk model: divisor must be positive", name)
+	}
+	if p["contained_at"] < 1 || p["contained_at"] > p["extreme_at"] || p["extreme_at"] > 10 {
+		return parametricModel{}, fmt.Errorf("%s risk model: thresholds must satisfy 1 <= contained_at <= extreme_at <= 10", name)
+	}
+	for _, k := range []string{"recovery_neutralized", "recovery_contained", "recovery_extreme"} {
+		if p[k] < 0 || p[k] > 1 {
+			return parametricModel{}, fmt.Errorf("%s risk model: %s must be between 0 and 1", name, k)
+		}
+	}
+	return m, nil
+}
+
+func (m parametricModel) Name() string { return m.name }
+
+func (m parametricModel) Params() map[string]float64 {
+	out := make(map[string]float64, len(m.params))
+	for k, v := range m.params {
+		out[k] = v
+	}
+	return out
+}
+
+func (m parametricModel) Risk(threat, units, capacity int) int {
+	p := m.params
+	pressure := float64(threat)*p["threat_weight"] + float64(units)*p["units_weight"] + float64(capacity)*p["capacity_weight"]
+	raw := int(math.Round(pressure / p["divisor"]))
+	if raw < 1 {
+		return 1
+	}
+	if raw > 10 {
+		return 10
+	}
+	return raw
+}
+
+func (m parametricModel) Outcome(risk int, enoughCapacity bool) string {
+	if !enoughCapacity {
+		return "FAILED: insufficient fleet capacity"
+	}
+	if float64(risk) >= m.params["extreme_at"] {
+		return "EXTREME RESISTANCE"
+	}
+	if float64(risk) >= m.params["contained_at"] {
+		return "CONTAINED"
+	}
+	return "NEUTRALIZED"
+}
+
+func (m parametricModel) RecoveryRate(outcome string) float64 {
+	switch outcome {
+	case "NEUTRALIZED":
+		return m.params["recovery_neutralized"]
+	case "CONTAINED":
+		return m.params["recovery_contained"]
+	case "EXTREME RESISTANCE":
+		return m.params["recovery_extreme"]
+	default:
+		return 0
+	}
+}
+
+// standardModel is the built-in formula. It accepts its own parameters back
+// but refuses to change them; use the parametric model to tune them.
+var standardModel, _ = newParametricModel(DefaultRiskModel, nil)
+
+func init() {
+	RegisterRiskModel(DefaultRiskModel, func(params map[string]float64) (RiskModel, error) {
+		for k, v := range params {
+			if want, ok := standardRiskParams[k]; !ok || v != want {
+				return nil, fmt.Errorf("standard risk model is fixed: use the parametric model to set %q", k)
+			}
+		}
+		return standardModel, nil
+	}, "default")
+	RegisterRiskModel("parametric", func(params map[string]float64) (RiskModel, error) {
+		return newParametricModel("parametric", params)
+	})
+}
+
+// missionRiskModel rebuilds the model stamped on m, falling back to the
+// standard model for missions recorded before models were stamped or whose
+// model is not registered in this build.
+func missionRiskModel(m Mission) RiskModel {
+	if m.RiskModel == "" {
+		return standardModel
+	}
+	model, err := NewRiskModel(m.RiskModel, m.RiskParams)
+	if err != nil {
+		return standardModel
+	}
+	return model
+}
@@ -0,0 +1,123 @@
+package skynet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fixedRisk struct{ score int }
+
+func (f fixedRisk) Name() string { return "fixed-test" }
+
+func (f fixedRisk) Params() map[string]float64 {
+	return map[string]float64{"score": float64(f.score)}
+}
+
+func (f fixedRisk) Risk(threat, units, capacity int) int { return f.score }
+
+func (f fixedRisk) Outcome(risk int, enoughCapacity bool) string {
+	return standardModel.Outcome(risk, enoughCapacity)
+}
+
+func (f fixedRisk) RecoveryRate(outcome string) float64 { return 1 }
+
+func init() {
+	RegisterRiskModel("fixed-test", func(params map[string]float64) (RiskModel, error) {
+		return fixedRisk{score: int(params["score"])}, nil
+	})
+}
+
+func TestStandardRiskModelMatchesDefaults(t *testing.T) {
+	model, err := NewRiskModel("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Name() != DefaultRiskModel || model.Risk(7, 4, 10) != ComputeRisk(7, 4, 10) || model.RecoveryRate("CONTAINED") != 0.5 {
+		t.Fatalf("unexpected standard model %+v", model.Params())
+	}
+	if _, err := NewRiskModel("standard", model.Params()); err != nil {
+		t.Fatalf("standard model should accept its own params: %v", err)
+	}
+	if _, err := NewRiskModel("standard", map[string]float64{"divisor": 3}); err == nil {
+		t.Fatal("expected standard model to refuse tuning")
+	}
+}
+
+func TestParametricRiskModel(t *testing.T) {
+	model, err := NewRiskModel("parametric", map[string]float64{"threat_weight": 2, "contained_at": 3, "recovery_contained": 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (7*2 + 4*0.6 - 10*0.35) / 2 = 6.45
+	if risk := model.Risk(7, 4, 10); risk != 6 {
+		t.Fatalf("expected risk 6, got %d", risk)
+	}
+	if model.Outcome(3, true) != "CONTAINED" || model.RecoveryRate("CONTAINED") != 0.9 {
+		t.Fatalf("unexpected thresholds or rates: %+v", model.Params())
+	}
+	for _, params := range []map[string]float64{{"bogus": 1}, {"divisor": 0}, {"contained_at": 9}, {"recovery_extreme": 2}} {
+		if _, err := NewRiskModel("parametric", params); err == nil {
+			t.Fatalf("expected error for %v", params)
+		}
+	}
+	if _, err := NewRiskModel("nope", nil); err == nil {
+		t.Fatal("expected unknown model error")
+	}
+}
+
+func TestDispatchStampsRiskModel(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 2}},
+	})
+	model, _ := NewRiskModel("fixed-test", map[string]float64{"score": 9})
+
+	mission, err := DispatchWith(&st, "hq", 4, DispatchOptions{Risk: model})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if mission.RiskScore != 9 || mission.Outcome != "EXTREME RESISTANCE" || mission.Recovered != 4 {
+		t.Fatalf("custom model not applied: %+v", mission)
+	}
+	if mission.RiskModel != "fixed-test" || mission.RiskParams["score"] != 9 {
+		t.Fatalf("model not stamped: %+v", mission)
+	}
+	plain, _ := Dispatch(&st, "hq", 1)
+	if plain.RiskModel != DefaultRiskModel || plain.RiskParams["threat_weight"] != 1.2 {
+		t.Fatalf("standard model not stamped: %+v", plain)
+	}
+}
+
+func TestPhasedMissionRecoversWithStampedModel(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 4}, {Name: "beta", Capacity: 6}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	model, _ := NewRiskModel("parametric", map[string]float64{"recovery_neutralized": 1})
+	mission, err := DispatchWith(&st, "hq", 4, DispatchOptions{Lifecycle: MissionLifecycle{EnRoute: 1}, Risk: model})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if mission.Outcome != "NEUTRALIZED" {
+		t.Fatalf("unexpected outcome %s", mission.Outcome)
+	}
+	if _, err := Tick(&st, 1, SimulationConfig{}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	if st.Missions[0].Recovered != 4 || st.Missions[0].NetLoss != 0 {
+		t.Fatalf("expected full recovery under the stamped model: %+v", st.Missions[0])
+	}
+}
+
+func TestLoadConfigRejectsBadRiskModel(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(ConfigPath(statePath), []byte(`{"risk":{"model":"parametric","params":{"divisor":-1}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(statePath); err == nil {
+		t.Fatal("expected invalid risk config error")
+	}
+}
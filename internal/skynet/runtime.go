@@ -0,0 +1,147 @@
+package skynet
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Clock supplies the wall-clock time the engine stamps records with while
+// the simulated clock is not running.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the host clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now().UTC() }
+
+// FixedClock always reports the same instant, for reproducible runs.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c).UTC() }
+
+// ParseClock turns a -clock value into a Clock: "system" (or empty) for
+// the host clock, otherwise an RFC3339 instant for a FixedClock.
+func ParseClock(value string) (Clock, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "system") {
+		return SystemClock{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("clock must be \"system\" or an RFC3339 time: %w", err)
+	}
+	return FixedClock(t), nil
+}
+
+// IDGenerator issues mission IDs.
+type IDGenerator interface {
+	// MissionID returns an ID for a mission created at at that no mission
+	// in st uses yet, and the value to store in Core.MissionSeq.
+	MissionID(st State, at time.Time) (string, int)
+}
+
+// DefaultMissionPrefix starts sequential mission IDs unless a workspace
+// configures its own prefix.
+const DefaultMissionPrefix = "M"
+
+// SequentialIDs issues IDs such as M-000123 from the counter persisted in
+// Core.MissionSeq, skipping any ID already taken.
+type SequentialIDs struct {
+	// Prefix replaces DefaultMissionPrefix when set.
+	Prefix string
+}
+
+func (g SequentialIDs) MissionID(st State, _ time.Time) (string, int) {
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = DefaultMissionPrefix
+	}
+	seq := st.Core.MissionSeq
+	for {
+		seq++
+		id := fmt.Sprintf("%s-%06d", prefix, seq)
+		if findMissionIndex(st.Missions, id) < 0 {
+			return id, seq
+		}
+	}
+}
+
+// ValidateMissionPrefix checks a configured mission ID prefix.
+func ValidateMissionPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("mission prefix must not be empty")
+	}
+	for _, r := range prefix {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return fmt.Errorf("mission prefix %q may contain only letters, digits, '-' and '_'", prefix)
+		}
+	}
+	return nil
+}
+
+// Engine is the context the engine draws time, IDs and randomness from.
+// States use DefaultEngine until SetEngine attaches another one.
+type Engine struct {
+	Clock Clock
+	IDs   IDGenerator
+	// Seed seeds the engine's randomness, such as the war game, so runs
+	// with the same seed repeat exactly.
+	Seed int64
+
+	rng *rand.Rand
+}
+
+// Rand returns the engine's random source, seeded from Seed on first use.
+func (e *Engine) Rand() *rand.Rand {
+	if e.rng == nil {
+		e.rng = rand.New(rand.NewSource(e.Seed))
+	}
+	return e.rng
+}
+
+// NewEngine returns an engine with the given clock and seed.
+func NewEngine(clock Clock, seed int64) *Engine {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &Engine{Clock: clock, IDs: SequentialIDs{}, Seed: seed}
+}
+
+// DefaultEngine uses the host clock and a time-based seed.
+func DefaultEngine() *Engine {
+	return NewEngine(SystemClock{}, time.Now().UnixNano())
+}
+
+// SetEngine makes every engine operation on st use e. Clones share it.
+func (st *State) SetEngine(e *Engine) {
+	st.engine = e
+}
+
+// Engine returns the engine attached to st, or a default one.
+func (st State) Engine() *Engine {
+	if st.engine == nil {
+		return DefaultEngine()
+	}
+	return st.engine
+}
+
+// wallClock is the engine clock's current time, ignoring simulated time.
+func (st State) wallClock() time.Time {
+	if st.engine == nil || st.engine.Clock == nil {
+		return time.Now().UTC()
+	}
+	return st.engine.Clock.Now().UTC()
+}
+
+// newMissionID asks the engine for the ID of a mission created now.
+func (st State) newMissionID() (string, int) {
+	ids := IDGenerator(SequentialIDs{})
+	if st.engine != nil && st.engine.IDs != nil {
+		ids = st.engine.IDs
+	}
+	return ids.MissionID(st, st.wallClock())
+}
@@ -0,0 +1,179 @@
+package skynet
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func scriptedRun(t *testing.T) []byte {
+	t.Helper()
+	clock, err := ParseClock("2030-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewState()
+	st.SetEngine(NewEngine(clock, 7))
+	Awaken(&st, "offense")
+	if err := AddNode(&st, "alpha", 6); err != nil {
+		t.Fatalf("add node: %v", err)
+	}
+	if err := AddTarget(&st, "hq", 5); err != nil {
+		t.Fatalf("add target: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := Dispatch(&st, "hq", 2); err != nil {
+			t.Fatalf("dispatch: %v", err)
+		}
+	}
+	data, err := json.Marshal(struct {
+		State  State
+		Events []Event
+	}{st, st.PendingEvents()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFixedEngineIsReproducible(t *testing.T) {
+	first, second := scriptedRun(t), scriptedRun(t)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("runs differ:\n%s\n%s", first, second)
+	}
+}
+
+func TestSequentialIDsStepPastTakenIDs(t *testing.T) {
+	st := NewState()
+	st.Core.MissionSeq = 4
+	st.Missions = []Mission{{ID: "M-1700000000000000000"}, {ID: "M-000005"}}
+	if id, seq := st.newMissionID(); id != "M-000006" || seq != 6 {
+		t.Fatalf("expected M-000006/6, got %s/%d", id, seq)
+	}
+	st.SetEngine(&Engine{Clock: SystemClock{}, IDs: SequentialIDs{Prefix: "EAST"}})
+	if id, _ := st.newMissionID(); id != "EAST-000005" {
+		t.Fatalf("expected EAST-000005, got %s", id)
+	}
+}
+
+func TestDispatchPersistsMissionCounter(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 10}},
+		Targets: []Target{{Name: "hq", Threat: 3}},
+	})
+	first, _ := Dispatch(&st, "hq", 1)
+	second, _ := Dispatch(&st, "hq", 1)
+	if first.ID != "M-000001" || second.ID != "M-000002" || st.Core.MissionSeq != 2 {
+		t.Fatalf("unexpected IDs %s %s seq=%d", first.ID, second.ID, st.Core.MissionSeq)
+	}
+
+	replayed := NewState()
+	for _, ev := range st.PendingEvents() {
+		if err := ApplyEvent(&replayed, ev); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if replayed.Core.MissionSeq != 2 {
+		t.Fatalf("replay lost the mission counter: %d", replayed.Core.MissionSeq)
+	}
+}
+
+func TestValidateMissionPrefix(t *testing.T) {
+	for _, prefix := range []string{"M", "EAST-2", "ops_west"} {
+		if err := ValidateMissionPrefix(prefix); err != nil {
+			t.Fatalf("expected %q to be valid: %v", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"", "ops/east", "a b"} {
+		if ValidateMissionPrefix(prefix) == nil {
+			t.Fatalf("expected %q to be invalid", prefix)
+		}
+	}
+}
+
+func TestLoadConfigRejectsBadMissionPrefix(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(ConfigPath(statePath), []byte(`{"mission_prefix":"ops/east"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(statePath); err == nil {
+		t.Fatal("expected invalid mission prefix error")
+	}
+}
+
+func TestEngineClockStampsRecords(t *testing.T) {
+	st := NewState()
+	st.SetEngine(NewEngine(FixedClock(time.Date(2031, 5, 1, 12, 0, 0, 0, time.UTC)), 1))
+	Awaken(&st, "defense")
+	if st.Core.LastAwaken != "2031-05-01T12:00:00Z" {
+		t.Fatalf("unexpected awaken time %s", st.Core.LastAwaken)
+	}
+	if _, err := Tick(&st, 1, SimulationConfig{}, ThreatDynamics{}); err != nil {
+		t.Fatal(err)
+	}
+	if st.Core.SimTime != "2031-05-01T13:00:00Z" {
+		t.Fatalf("simulated clock should start at the engine clock, got %s", st.Core.SimTime)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if c, err := ParseClock("system"); err != nil || c != (SystemClock{}) {
+		t.Fatalf("unexpected system clock: %v %v", c, err)
+	}
+	if _, err := ParseClock("yesterday"); err == nil {
+		t.Fatal("expected error for invalid clock")
+	}
+}
+
+func TestEngineRandIsSeeded(t *testing.T) {
+	a, b := NewEngine(nil, 11), &Engine{Seed: 11}
+	for i := 0; i < 3; i++ {
+		if x, y := a.Rand().Int63(), b.Rand().Int63(); x != y {
+			t.Fatalf("draw %d differs: %d vs %d", i, x, y)
+		}
+	}
+}
+
+func TestEngineClockStampsStoreRecords(t *testing.T) {
+	dir := t.TempDir()
+	st := NewState()
+	st.SetEngine(NewEngine(FixedClock(time.Date(2031, 5, 1, 12, 0, 0, 0, time.UTC)), 1))
+	Awaken(&st, "defense")
+	// A change without a typed event makes the journal append state_replaced.
+	st.Core.Mode = "offense"
+	const want = "2031-05-01T12:00:00Z"
+
+	journal := NewJournalStore(filepath.Join(dir, "journal", "state.json"))
+	journal.SnapshotEvery = 1
+	if err := journal.Save(st); err != nil {
+		t.Fatalf("journal save: %v", err)
+	}
+	events, err := journal.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.At != want {
+			t.Fatalf("event %s stamped %s", ev.Type, ev.At)
+		}
+	}
+	if snap, ok, err := journal.readSnapshot(); err != nil || !ok || snap.At != want {
+		t.Fatalf("unexpected snapshot time %q ok=%v err=%v", snap.At, ok, err)
+	}
+
+	historyPath := filepath.Join(dir, "history", "state.json")
+	history := WithHistory(NewStore(historyPath), historyPath, "awaken")
+	if err := history.Save(st); err != nil {
+		t.Fatalf("history save: %v", err)
+	}
+	if log, err := history.History(); err != nil || log.Revisions[0].At != want {
+		t.Fatalf("unexpected revision time: %+v err=%v", log.Revisions, err)
+	}
+
+	if bundle, err := NewBundle(st, "default"); err != nil || bundle.ExportedAt != want {
+		t.Fatalf("unexpected export time %q err=%v", bundle.ExportedAt, err)
+	}
+}
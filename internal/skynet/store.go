@@ -3,20 +3,62 @@ package skynet
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-type Store struct {
+const (
+	BackendFile    = "file"
+	BackendJournal = "journal"
+)
+
+// Store persists State. FileStore keeps a single JSON snapshot; JournalStore
+// keeps an append-only event log with periodic snapshots.
+type Store interface {
+	Load() (State, error)
+	Save(State) error
+	Lock(timeout time.Duration) (*Lock, error)
+	PlanMigration() (MigrationPlan, error)
+}
+
+type FileStore struct {
 	Path string
+	// Key encrypts the file at rest when set.
+	Key *StateKey
 }
 
-func NewStore(path string) Store {
-	return Store{Path: path}
+func NewStore(path string) FileStore {
+	return FileStore{Path: path}
+}
+
+// OpenStore returns the store for the state at path, encrypted with key when
+// it is non-nil. An empty backend picks the journal when one exists next to
+// path and the plain file otherwise.
+func OpenStore(path, backend string, key *StateKey) (Store, error) {
+	if backend == "" {
+		backend = BackendFile
+		if journalExists(path) {
+			backend = BackendJournal
+		}
+	}
+	switch backend {
+	case BackendFile:
+		s := NewStore(path)
+		s.Key = key
+		return s, nil
+	case BackendJournal:
+		s := NewJournalStore(path)
+		s.Key = key
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown state backend %q (want %s or %s)", backend, BackendFile, BackendJournal)
+	}
 }
 
-func (s Store) Load() (State, error) {
-	data, err := os.ReadFile(s.Path)
+func (s FileStore) Load() (State, error) {
+	data, err := readSealedFile(s.Path, s.Key)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return NewState(), nil
@@ -24,28 +66,88 @@ func (s Store) Load() (State, error) {
 		return State{}, err
 	}
 
-	var st State
-	if err := json.Unmarshal(data, &st); err != nil {
-		return State{}, err
-	}
-	return st, nil
+	return decodeState(data)
 }
 
-func (s Store) Save(st State) error {
+// Save replaces the state file atomically: readers and crashes only ever
+// observe the previous or the new document, never a partial write.
+func (s FileStore) Save(st State) error {
 	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
 		return err
 	}
+	st.SchemaVersion = CurrentSchemaVersion
 	data, err := json.MarshalIndent(st, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.Path, data, 0o644)
+	return writeSealedFile(s.Path, data, s.Key)
 }
 
+// Lock takes the advisory lock guarding the state file. Callers hold it
+// across a Load -> mutate -> Save cycle. With a zero timeout it fails
+// immediately with ErrLocked when another process holds the lock.
+func (s FileStore) Lock(timeout time.Duration) (*Lock, error) {
+	return lockStatePath(s.Path, timeout)
+}
+
+// storeKey returns the encryption key used by s, looking through wrappers.
+func storeKey(s Store) *StateKey {
+	switch s := s.(type) {
+	case FileStore:
+		return s.Key
+	case JournalStore:
+		return s.Key
+	case HistoryStore:
+		return storeKey(s.Store)
+	case ArchivingStore:
+		return storeKey(s.Store)
+	}
+	return nil
+}
+
+func lockStatePath(path string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return acquireLock(path+".lock", timeout)
+}
+
+// DefaultStatePath returns the state file of the active workspace.
 func DefaultStatePath() string {
-	base := os.Getenv("SKYNET_HOME")
-	if base == "" {
-		base = ".skynet"
+	home := HomeDir()
+	return WorkspaceStatePath(home, ActiveWorkspace(home))
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpPath)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
 	}
-	return filepath.Join(base, "state.json")
+	return nil
 }
@@ -1,8 +1,12 @@
 package skynet
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestStoreLoadAndSave(t *testing.T) {
@@ -40,3 +44,72 @@ func TestStoreLoadAndSave(t *testing.T) {
 		t.Fatalf("expected deployed=0, got %d", loaded.Nodes[0].Deployed)
 	}
 }
+
+func TestStoreSaveLeavesNoTempFiles(t *testing.T) {
+	tmp := t.TempDir()
+	store := NewStore(filepath.Join(tmp, "state.json"))
+
+	st := NewState()
+	Awaken(&st, "defense")
+	for i := 0; i < 3; i++ {
+		if err := store.Save(st); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.json" {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected only state.json, got %v", names)
+	}
+}
+
+func TestStoreLockIsExclusive(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state.json"))
+
+	lock, err := store.Lock(0)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	if _, err := store.Lock(0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+
+	again, err := store.Lock(0)
+	if err != nil {
+		t.Fatalf("relock after unlock: %v", err)
+	}
+	again.Unlock()
+}
+
+func TestStaleLockDetection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json.lock")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if staleLock(path) {
+		t.Fatal("a lock held by a live process is not stale")
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if !staleLock(path) {
+		t.Fatal("expected an old lock file to be stale")
+	}
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !staleLock(path) {
+		t.Fatal("expected a lock naming a missing process to be stale")
+	}
+}
@@ -0,0 +1,106 @@
+package skynet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Threat history reasons recorded by the engine.
+const (
+	ThreatReasonRegistered = "registered"
+	ThreatReasonSet        = "set"
+)
+
+// LookupTarget returns the target with the given name (case-insensitive).
+func LookupTarget(st State, name string) (Target, error) {
+	idx, err := targetIndex(&st, name)
+	if err != nil {
+		return Target{}, err
+	}
+	return st.Targets[idx], nil
+}
+
+// RemoveTarget deletes a target from the registry. Targets still referenced
+// by missions in the hot state are only removed when force is set; archiving
+// keeps them for reports instead.
+func RemoveTarget(st *State, name string, force bool) (Target, error) {
+	idx, err := targetIndex(st, name)
+	if err != nil {
+		return Target{}, err
+	}
+	target := st.Targets[idx]
+	if n := len(TargetMissions(*st, target.Name)); n > 0 && !force {
+		return Target{}, fmt.Errorf("target %q is referenced by %d mission(s): archive it instead or use -force", target.Name, n)
+	}
+	st.Targets = append(st.Targets[:idx:idx], st.Targets[idx+1:]...)
+	st.record(EventTargetRemoved, st.timestamp(), TargetRemovedEvent{Name: target.Name})
+	return target, nil
+}
+
+// ArchiveTarget retires a target without deleting it: archived targets keep
+// their history but are excluded from dispatch and game planning. Passing
+// archived=false restores the target.
+func ArchiveTarget(st *State, name string, archived bool) (Target, error) {
+	idx, err := targetIndex(st, name)
+	if err != nil {
+		return Target{}, err
+	}
+	t := &st.Targets[idx]
+	at := st.timestamp()
+	t.Archived = archived
+	t.UpdatedAt = at
+	st.record(EventTargetSet, at, TargetSetEvent{Target: *t})
+	return *t, nil
+}
+
+// TargetMissions returns the hot-state missions dispatched against a target.
+func TargetMissions(st State, name string) []Mission {
+	var out []Mission
+	for _, m := range st.Missions {
+		if strings.EqualFold(m.Target, name) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// TargetStatus describes a target's lifecycle state for display.
+func TargetStatus(t Target) string {
+	if t.Archived {
+		return "archived"
+	}
+	return "active"
+}
+
+func targetIndex(st *State, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return -1, fmt.Errorf("target name is required")
+	}
+	idx := findTargetIndex(st.Targets, name)
+	if idx < 0 {
+		return -1, fmt.Errorf("target %q not found", name)
+	}
+	return idx, nil
+}
+
+// upgradeLegacyTarget rebuilds the v2 timestamps and threat history for a
+// target_set event recorded before they existed, mirroring what AddTarget
+// does today.
+func upgradeLegacyTarget(targets []Target, idx int, t Target, at string) Target {
+	if idx < 0 {
+		t.CreatedAt = at
+		t.UpdatedAt = at
+		t.ThreatHistory = []ThreatChange{{At: at, Threat: t.Threat, Reason: ThreatReasonRegistered}}
+		return t
+	}
+	prev := targets[idx]
+	t.CreatedAt = prev.CreatedAt
+	t.UpdatedAt = at
+	t.Archived = prev.Archived
+	t.ThreatHistory = append([]ThreatChange(nil), prev.ThreatHistory...)
+	if prev.Threat != t.Threat {
+		t.ThreatHistory = append(t.ThreatHistory, ThreatChange{At: at, Threat: t.Threat, Reason: ThreatReasonSet})
+	}
+	return t
+}
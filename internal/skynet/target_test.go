@@ -0,0 +1,79 @@
+package skynet
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddTargetKeepsCreatedAndRecordsThreatHistory(t *testing.T) {
+	st := NewState()
+	if err := AddTarget(&st, "hq", 4); err != nil {
+		t.Fatal(err)
+	}
+	created := st.Targets[0].CreatedAt
+	for _, threat := range []int{4, 9} {
+		if err := AddTarget(&st, "HQ", threat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tg := st.Targets[0]
+	if tg.CreatedAt != created || tg.UpdatedAt == "" {
+		t.Fatalf("unexpected timestamps: %+v", tg)
+	}
+	if len(tg.ThreatHistory) != 2 || tg.ThreatHistory[0].Reason != ThreatReasonRegistered || tg.ThreatHistory[1].Threat != 9 {
+		t.Fatalf("unexpected threat history: %+v", tg.ThreatHistory)
+	}
+}
+
+func TestArchivedTargetIsExcludedFromDispatchAndPlanning(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 5}},
+	})
+	if _, err := ArchiveTarget(&st, "hq", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Dispatch(&st, "hq", 1); err == nil {
+		t.Fatal("expected dispatch to archived target to fail")
+	}
+	if _, err := PlanGame(st, 3, 1.2); err == nil {
+		t.Fatal("expected planning without active targets to fail")
+	}
+	if err := AddTarget(&st, "hq", 6); err != nil {
+		t.Fatal(err)
+	}
+	if st.Targets[0].Archived {
+		t.Fatal("re-registering should restore an archived target")
+	}
+}
+
+func TestRemoveTargetRefusesWhenReferenced(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Nodes:   []Node{{Name: "alpha", Capacity: 5}},
+		Targets: []Target{{Name: "hq", Threat: 5}},
+	})
+	if _, err := Dispatch(&st, "hq", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoveTarget(&st, "hq", false); err == nil {
+		t.Fatal("expected refusal for referenced target")
+	}
+	if _, err := RemoveTarget(&st, "hq", true); err != nil || len(st.Targets) != 0 {
+		t.Fatalf("forced remove failed: err=%v targets=%+v", err, st.Targets)
+	}
+}
+
+func TestLegacyTargetEventReplay(t *testing.T) {
+	st := NewState()
+	for i, threat := range []int{3, 3, 8} {
+		data, _ := json.Marshal(map[string]any{"target": map[string]any{"name": "hq", "threat": threat, "added_at": "ignored"}})
+		ev := Event{Seq: i + 1, Type: EventTargetSet, At: []string{"t1", "t2", "t3"}[i], Data: data}
+		if err := ApplyEvent(&st, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tg := st.Targets[0]
+	if tg.CreatedAt != "t1" || tg.UpdatedAt != "t3" || len(tg.ThreatHistory) != 2 {
+		t.Fatalf("unexpected replayed target: %+v", tg)
+	}
+}
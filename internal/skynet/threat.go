@@ -0,0 +1,128 @@
+package skynet
+
+import (
+	"fmt"
+	"time"
+)
+
+// Threat history reasons recorded by threat dynamics.
+const (
+	ThreatReasonDecay       = "decay"
+	ThreatReasonEscalation  = "escalation"
+	ThreatReasonNeutralized = "neutralized"
+)
+
+// ThreatDynamics evolves target threat over time and in response to
+// missions. The zero value disables every rule.
+type ThreatDynamics struct {
+	// DecayEvery lowers an active target's threat by one for each period it
+	// goes unattended (no mission and no threat change), e.g. "24h".
+	DecayEvery string `json:"decay_every,omitempty"`
+	// DecayFloor is the lowest threat decay reaches (default 1).
+	DecayFloor int `json:"decay_floor,omitempty"`
+	// EscalateOnFailure raises threat after a FAILED mission.
+	EscalateOnFailure int `json:"escalate_on_failure,omitempty"`
+	// ReduceOnNeutralized lowers threat after a NEUTRALIZED mission.
+	ReduceOnNeutralized int `json:"reduce_on_neutralized,omitempty"`
+}
+
+func (d ThreatDynamics) decayInterval() (time.Duration, error) {
+	if d.DecayEvery == "" {
+		return 0, nil
+	}
+	every, err := time.ParseDuration(d.DecayEvery)
+	if err != nil {
+		return 0, fmt.Errorf("threat_dynamics.decay_every: %w", err)
+	}
+	if every <= 0 {
+		return 0, fmt.Errorf("threat_dynamics.decay_every must be positive")
+	}
+	return every, nil
+}
+
+func (d ThreatDynamics) decayFloor() int {
+	if d.DecayFloor < 1 {
+		return 1
+	}
+	return d.DecayFloor
+}
+
+func (d ThreatDynamics) validate() error {
+	if _, err := d.decayInterval(); err != nil {
+		return err
+	}
+	if d.DecayFloor < 0 || d.DecayFloor > 10 {
+		return fmt.Errorf("threat_dynamics.decay_floor must be between 0 and 10")
+	}
+	if d.EscalateOnFailure < 0 || d.ReduceOnNeutralized < 0 {
+		return fmt.Errorf("threat_dynamics escalation and reduction must be >= 0")
+	}
+	return nil
+}
+
+// applyMissionThreat marks the target as engaged and applies the
+// escalation or reduction rule for the mission outcome.
+func applyMissionThreat(t *Target, m Mission, d ThreatDynamics, at string) {
+	t.LastEngagedAt = at
+	switch {
+	case !isMissionSuccess(m) && d.EscalateOnFailure > 0:
+		setThreat(t, clampThreat(t.Threat+d.EscalateOnFailure), ThreatReasonEscalation, at)
+	case m.Outcome == "NEUTRALIZED" && d.ReduceOnNeutralized > 0:
+		setThreat(t, clampThreat(t.Threat-d.ReduceOnNeutralized), ThreatReasonNeutralized, at)
+	}
+}
+
+// decayTargets applies decay to active targets unattended as of now and
+// returns the targets that changed.
+func decayTargets(st *State, d ThreatDynamics, now time.Time) ([]Target, error) {
+	every, err := d.decayInterval()
+	if err != nil || every == 0 {
+		return nil, err
+	}
+	floor := d.decayFloor()
+	var changed []Target
+	for i := range st.Targets {
+		t := &st.Targets[i]
+		if t.Archived || t.Threat <= floor {
+			continue
+		}
+		since, ok := lastAttended(*t)
+		if !ok {
+			continue
+		}
+		decayed := false
+		for next := since.Add(every); !next.After(now) && t.Threat > floor; next = next.Add(every) {
+			setThreat(t, t.Threat-1, ThreatReasonDecay, next.UTC().Format(time.RFC3339))
+			decayed = true
+		}
+		if decayed {
+			changed = append(changed, *t)
+		}
+	}
+	return changed, nil
+}
+
+// lastAttended is the latest of creation, last engagement and last threat
+// change.
+func lastAttended(t Target) (time.Time, bool) {
+	stamps := []string{t.CreatedAt, t.LastEngagedAt}
+	if n := len(t.ThreatHistory); n > 0 {
+		stamps = append(stamps, t.ThreatHistory[n-1].At)
+	}
+	var latest time.Time
+	for _, s := range stamps {
+		if ts, err := time.Parse(time.RFC3339, s); err == nil && ts.After(latest) {
+			latest = ts
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+func setThreat(t *Target, threat int, reason, at string) {
+	if threat == t.Threat {
+		return
+	}
+	t.Threat = threat
+	t.UpdatedAt = at
+	t.ThreatHistory = append(t.ThreatHistory, ThreatChange{At: at, Threat: threat, Reason: reason})
+}
@@ -0,0 +1,69 @@
+package skynet
+
+import "testing"
+
+func TestMissionOutcomeAdjustsThreat(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}},
+		Targets: []Target{{Name: "hq", Threat: 6}, {Name: "depot", Threat: 4}},
+	})
+	dyn := ThreatDynamics{EscalateOnFailure: 2, ReduceOnNeutralized: 3}
+	if _, err := DispatchWith(&st, "hq", 5, DispatchOptions{Threat: dyn}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DispatchWith(&st, "depot", 1, DispatchOptions{Threat: dyn}); err != nil {
+		t.Fatal(err)
+	}
+	hq, _ := LookupTarget(st, "hq")
+	depot, _ := LookupTarget(st, "depot")
+	if hq.Threat != 8 || hq.ThreatHistory[len(hq.ThreatHistory)-1].Reason != ThreatReasonEscalation {
+		t.Fatalf("expected escalation: %+v", hq)
+	}
+	if depot.Threat != 1 || depot.LastEngagedAt == "" {
+		t.Fatalf("expected reduction: %+v", depot)
+	}
+}
+
+func TestTickDecaysUnattendedTargets(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}},
+		Targets: []Target{{Name: "hq", Threat: 6}, {Name: "depot", Threat: 4}},
+	})
+	dyn := ThreatDynamics{DecayEvery: "3h", DecayFloor: 4}
+	result, err := Tick(&st, 10, SimulationConfig{}, dyn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hq, _ := LookupTarget(st, "hq")
+	depot, _ := LookupTarget(st, "depot")
+	if hq.Threat != 4 || depot.Threat != 4 || len(result.Decayed) != 1 {
+		t.Fatalf("unexpected decay: hq=%d depot=%d decayed=%+v", hq.Threat, depot.Threat, result.Decayed)
+	}
+	if got := hq.ThreatHistory[len(hq.ThreatHistory)-1].At; got != "2030-01-01T07:00:00Z" {
+		t.Fatalf("decay should be stamped at the period boundary, got %s", got)
+	}
+}
+
+func TestDispatchedEventReplaysThreatChange(t *testing.T) {
+	st := testFleet(t, fleetSpec{
+		Start:   "2030-01-01T00:00:00Z",
+		Nodes:   []Node{{Name: "alpha", Capacity: 3}},
+		Targets: []Target{{Name: "hq", Threat: 6}, {Name: "depot", Threat: 4}},
+	})
+	base := st
+	base.Targets = append([]Target(nil), st.Targets...)
+	st.events = nil
+	if _, err := DispatchWith(&st, "hq", 5, DispatchOptions{Threat: ThreatDynamics{EscalateOnFailure: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range st.PendingEvents() {
+		if err := ApplyEvent(&base, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.Targets[0].Threat != 7 {
+		t.Fatalf("replayed threat not applied: %+v", base.Targets[0])
+	}
+}
@@ -0,0 +1,208 @@
+package skynet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultWorkspace    = "default"
+	workspacesDir       = "workspaces"
+	activeWorkspaceFile = "workspace"
+)
+
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type Workspace struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+}
+
+// HomeDir returns the skynet home directory holding every workspace.
+func HomeDir() string {
+	base := os.Getenv("SKYNET_HOME")
+	if base == "" {
+		base = ".skynet"
+	}
+	return base
+}
+
+// ActiveWorkspace returns the workspace selected by SKYNET_WORKSPACE or, when
+// unset, the one remembered by the last workspace switch.
+func ActiveWorkspace(home string) string {
+	if name := strings.TrimSpace(os.Getenv("SKYNET_WORKSPACE")); name != "" {
+		return name
+	}
+	data, err := os.ReadFile(filepath.Join(home, activeWorkspaceFile))
+	if err != nil {
+		return DefaultWorkspace
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" {
+		return DefaultWorkspace
+	}
+	return name
+}
+
+// WorkspaceStatePath returns the state file of a workspace. The default
+// workspace keeps the historical location directly under home.
+func WorkspaceStatePath(home, name string) string {
+	if name == DefaultWorkspace {
+		return filepath.Join(home, "state.json")
+	}
+	return filepath.Join(home, workspacesDir, name, "state.json")
+}
+
+func ListWorkspaces(home string) ([]Workspace, error) {
+	active := ActiveWorkspace(home)
+	names := []string{DefaultWorkspace}
+	entries, err := os.ReadDir(filepath.Join(home, workspacesDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && workspaceNamePattern.MatchString(e.Name()) && e.Name() != DefaultWorkspace {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names[1:])
+
+	workspaces := make([]Workspace, 0, len(names))
+	for _, name := range names {
+		workspaces = append(workspaces, Workspace{
+			Name:   name,
+			Path:   WorkspaceStatePath(home, name),
+			Active: name == active,
+		})
+	}
+	return workspaces, nil
+}
+
+func CreateWorkspace(home, name string, key *StateKey) error {
+	if err := validateNewWorkspace(home, name); err != nil {
+		return err
+	}
+	store := NewStore(WorkspaceStatePath(home, name))
+	store.Key = key
+	return store.Save(NewState())
+}
+
+// CloneWorkspace copies the current state of src into a new workspace dst,
+// together with its config.json and mission archive. History and journals
+// are not copied; the clone starts as a plain file, encrypted with key when
+// it is non-nil.
+func CloneWorkspace(home, src, dst string, key *StateKey) error {
+	if !WorkspaceExists(home, src) {
+		return fmt.Errorf("workspace %q not found", src)
+	}
+	if err := validateNewWorkspace(home, dst); err != nil {
+		return err
+	}
+	source, err := OpenStore(WorkspaceStatePath(home, src), "", key)
+	if err != nil {
+		return err
+	}
+	st, err := source.Load()
+	if err != nil {
+		return fmt.Errorf("load workspace %q: %w", src, err)
+	}
+	clone := NewStore(WorkspaceStatePath(home, dst))
+	clone.Key = key
+	if err := clone.Save(st); err != nil {
+		return err
+	}
+	srcDir := filepath.Dir(WorkspaceStatePath(home, src))
+	dstDir := filepath.Dir(clone.Path)
+	segments, err := filepath.Glob(filepath.Join(srcDir, archiveDir, "missions-*.jsonl.gz"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append([]string{filepath.Join(srcDir, configFile)}, segments...) {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if err := copyWorkspaceFile(path, filepath.Join(dstDir, rel)); err != nil {
+			return fmt.Errorf("clone workspace %q: %w", src, err)
+		}
+	}
+	return nil
+}
+
+// copyWorkspaceFile copies a file byte for byte, so sealed files stay
+// readable with the same key. A missing source file is skipped.
+func copyWorkspaceFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, data, info.Mode().Perm())
+}
+
+func SwitchWorkspace(home, name string) error {
+	if !WorkspaceExists(home, name) {
+		return fmt.Errorf("workspace %q not found", name)
+	}
+	if err := os.MkdirAll(home, 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(home, activeWorkspaceFile), []byte(name+"\n"), 0o644)
+}
+
+// DeleteWorkspace removes a workspace and everything stored in it. The
+// default and the active workspace cannot be deleted.
+func DeleteWorkspace(home, name string) error {
+	if name == DefaultWorkspace {
+		return fmt.Errorf("the %s workspace cannot be deleted", DefaultWorkspace)
+	}
+	if !WorkspaceExists(home, name) {
+		return fmt.Errorf("workspace %q not found", name)
+	}
+	if name == ActiveWorkspace(home) {
+		return fmt.Errorf("workspace %q is active: switch to another workspace first", name)
+	}
+	path := WorkspaceStatePath(home, name)
+	lock, err := lockStatePath(path, 0)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return os.RemoveAll(filepath.Dir(path))
+}
+
+func WorkspaceExists(home, name string) bool {
+	if name == DefaultWorkspace {
+		return true
+	}
+	if !workspaceNamePattern.MatchString(name) {
+		return false
+	}
+	info, err := os.Stat(filepath.Dir(WorkspaceStatePath(home, name)))
+	return err == nil && info.IsDir()
+}
+
+func validateNewWorkspace(home, name string) error {
+	if !workspaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	if WorkspaceExists(home, name) {
+		return fmt.Errorf("workspace %q already exists", name)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package skynet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceCloneSwitchDelete(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("SKYNET_HOME", home)
+	t.Setenv("SKYNET_WORKSPACE", "")
+
+	if got := DefaultStatePath(); got != filepath.Join(home, "state.json") {
+		t.Fatalf("expected default workspace path, got %s", got)
+	}
+
+	st := testFleet(t, fleetSpec{Nodes: []Node{{Name: "alpha", Capacity: 6}}})
+	if err := NewStore(DefaultStatePath()).Save(st); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if err := os.WriteFile(ConfigPath(DefaultStatePath()), []byte(`{"mission_prefix": "OPS"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMissionArchive(DefaultStatePath()).Append([]Mission{{ID: "OPS-1", Target: "hq"}}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	if err := CloneWorkspace(home, DefaultWorkspace, "sandbox", nil); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	sandbox := WorkspaceStatePath(home, "sandbox")
+	if cfg, err := LoadConfig(sandbox); err != nil || cfg.MissionPrefix != "OPS" {
+		t.Fatalf("config not cloned: %+v err=%v", cfg, err)
+	}
+	if archived, err := NewMissionArchive(sandbox).Missions(); err != nil || len(archived) != 1 || archived[0].ID != "OPS-1" {
+		t.Fatalf("archive not cloned: %+v err=%v", archived, err)
+	}
+	if err := CloneWorkspace(home, DefaultWorkspace, "sandbox", nil); err == nil {
+		t.Fatal("expected error cloning onto an existing workspace")
+	}
+	if err := SwitchWorkspace(home, "sandbox"); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+	if got := DefaultStatePath(); got != WorkspaceStatePath(home, "sandbox") {
+		t.Fatalf("expected sandbox path, got %s", got)
+	}
+	cloned, err := NewStore(DefaultStatePath()).Load()
+	if err != nil {
+		t.Fatalf("load clone: %v", err)
+	}
+	if len(cloned.Nodes) != 1 || cloned.Nodes[0].Name != "alpha" {
+		t.Fatalf("expected cloned node alpha, got %+v", cloned.Nodes)
+	}
+
+	if err := DeleteWorkspace(home, "sandbox"); err == nil {
+		t.Fatal("expected error deleting the active workspace")
+	}
+	if err := SwitchWorkspace(home, DefaultWorkspace); err != nil {
+		t.Fatalf("switch back: %v", err)
+	}
+	if err := DeleteWorkspace(home, "sandbox"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	workspaces, err := ListWorkspaces(home)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(workspaces) != 1 || workspaces[0].Name != DefaultWorkspace || !workspaces[0].Active {
+		t.Fatalf("unexpected workspaces after delete: %+v", workspaces)
+	}
+}
+
+func TestCreateWorkspaceValidatesName(t *testing.T) {
+	home := t.TempDir()
+	for _, name := range []string{"", "../escape", "a/b", DefaultWorkspace} {
+		if err := CreateWorkspace(home, name, nil); err == nil {
+			t.Fatalf("expected error for workspace name %q", name)
+		}
+	}
+}
@@ -2,110 +2,489 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"skynet-cli/internal/skynet"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	global := flag.NewFlagSet("skynet", flag.ContinueOnError)
+	global.Usage = usage
+	keyFile := global.String("key-file", "", "state encryption key file (default: SKYNET_KEY_FILE)")
+	seedFlag := global.String("seed", "", "random seed for reproducible runs (default: SKYNET_SEED or time-based)")
+	clockFlag := global.String("clock", "", "engine clock: system or a fixed RFC3339 time (default: SKYNET_CLOCK)")
+	mustParse(global, os.Args[1:])
+	rest := global.Args()
+	if len(rest) < 1 {
 		usage()
 		os.Exit(1)
 	}
 
+	cmd := strings.ToLower(rest[0])
+	args := rest[1:]
+	if cmd == "help" || cmd == "-h" || cmd == "--help" {
+		usage()
+		return
+	}
+	key := loadKeyOrDie(*keyFile)
+	engine, seeded := engineOrDie(*clockFlag, *seedFlag)
+
+	home := skynet.HomeDir()
+	if cmd == "workspace" {
+		// Managing workspaces must keep working when the active one is
+		// gone, since switching away is how that is repaired.
+		runWorkspace(args, home, key)
+		return
+	}
+	workspace := skynet.ActiveWorkspace(home)
+	if !skynet.WorkspaceExists(home, workspace) {
+		fatalf("active workspace %q not found: run skynet workspace switch %s", workspace, skynet.DefaultWorkspace)
+	}
 	path := skynet.DefaultStatePath()
-	store := skynet.NewStore(path)
+	store, err := skynet.OpenStore(path, os.Getenv("SKYNET_BACKEND"), key)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	location := path
+	if journal, ok := store.(skynet.JournalStore); ok {
+		location = journal.JournalPath()
+	}
+	cfg, err := skynet.LoadConfig(path)
+	if err != nil {
+		fatalf("failed to load config: %v", err)
+	}
+	engine.IDs = skynet.SequentialIDs{Prefix: cfg.MissionPrefix}
+	history := skynet.WithHistory(store, path, strings.Join(rest, " "))
+	archive := skynet.NewMissionArchive(path)
+	archive.Key = key
+	store = skynet.WithMissionArchive(history, path, cfg.Retention)
+	if mutatesState(cmd, args) {
+		lock, err := store.Lock(lockTimeout())
+		if err != nil {
+			fatalf("%v", err)
+		}
+		heldLock = lock
+		defer lock.Unlock()
+	}
 	st, err := store.Load()
 	if err != nil {
 		fatalf("failed to load state: %v", err)
 	}
-
-	cmd := strings.ToLower(os.Args[1])
-	args := os.Args[2:]
+	st.SetEngine(engine)
 
 	switch cmd {
 	case "awaken":
 		runAwaken(args, &st)
 		saveOrDie(store, st)
-		fmt.Printf("Skynet awakened in %q mode. state=%s\n", st.Core.Mode, path)
+		fmt.Printf("Skynet awakened in %q mode. state=%s\n", st.Core.Mode, location)
 	case "assimilate":
 		runAssimilate(args, &st)
 		saveOrDie(store, st)
 		fmt.Printf("Node assimilated. total_nodes=%d capacity=%d available=%d\n", len(st.Nodes), skynet.TotalCapacity(st.Nodes), skynet.AvailableCapacity(st.Nodes))
+	case "node":
+		runNode(args, &st)
+		saveOrDie(store, st)
 	case "target":
+		if len(args) > 0 && strings.EqualFold(args[0], "show") {
+			runTargetShow(args[1:], st)
+			break
+		}
 		runTarget(args, &st)
 		saveOrDie(store, st)
-		fmt.Printf("Target registry updated. total_targets=%d\n", len(st.Targets))
 	case "dispatch":
-		mission := runDispatch(args, &st)
+		mission, dispatched := runDispatch(args, &st, cfg)
+		if !dispatched {
+			break
+		}
 		saveOrDie(store, st)
 		fmt.Printf("Mission %s -> %s | risk=%d | outcome=%s | consumed=%d recovered=%d net_loss=%d | available=%d\n", mission.ID, mission.Target, mission.RiskScore, mission.Outcome, mission.Consumed, mission.Recovered, mission.NetLoss, skynet.AvailableCapacity(st.Nodes))
+		printShortfall(mission)
+		printPhase(mission)
+		printThreatShift(st, mission)
 	case "gameplan":
 		runGameplan(args, st)
 	case "wargame":
-		runWargame(args, st)
+		wargameSeed := int64(42)
+		if seeded {
+			wargameSeed = engine.Seed
+		}
+		runWargame(args, st, wargameSeed)
 	case "report":
-		runReport(args, st)
+		runReport(args, st, archive)
+	case "mission":
+		if runMission(args, &st, archive) {
+			saveOrDie(store, st)
+		}
+	case "archive":
+		runArchive(args, &st, archive, cfg.Retention)
+		saveOrDie(history, st)
 	case "status":
-		runStatus(st, path)
-	case "help", "-h", "--help":
-		usage()
+		runStatus(st, location, workspace)
+	case "migrate":
+		runMigrate(args, store, st)
+	case "journal":
+		runJournal(args, path, key, st)
+	case "history":
+		runHistory(args, history)
+	case "diff":
+		runDiff(args, home, workspace, key)
+	case "export":
+		runExport(args, st, workspace)
+	case "import":
+		runImport(args, &st)
+		saveOrDie(store, st)
+	case "tick":
+		runTick(args, &st, cfg)
+		saveOrDie(store, st)
+	case "apply":
+		if runApply(args, &st) {
+			saveOrDie(store, st)
+		}
+	case "undo":
+		runUndoRedo("undo", args, history)
+	case "redo":
+		runUndoRedo("redo", args, history)
+	case "fsck":
+		runFsck(args, store, &st)
+	case "rekey":
+		runRekey(args, path, key)
 	default:
 		fatalf("unknown command %q", cmd)
 	}
 }
 
 func runAwaken(args []string, st *skynet.State) {
-	fs := flag.NewFlagSet("awaken", flag.ExitOnError)
+	fs := flag.NewFlagSet("awaken", flag.ContinueOnError)
 	mode := fs.String("mode", "defense", "core mode")
 	mustParse(fs, args)
 	skynet.Awaken(st, *mode)
 }
 
 func runAssimilate(args []string, st *skynet.State) {
-	fs := flag.NewFlagSet("assimilate", flag.ExitOnError)
+	fs := flag.NewFlagSet("assimilate", flag.ContinueOnError)
 	name := fs.String("name", "", "node name")
 	capacity := fs.Int("capacity", 10, "node capacity")
+	labelSpec := fs.String("labels", "", "node labels, e.g. region=east,role=air")
+	priority := fs.Int("priority", 0, "weight for the weighted allocation strategy")
 	mustParse(fs, args)
-	if err := skynet.AddNode(st, *name, *capacity); err != nil {
+	labels, err := skynet.ParseLabels(*labelSpec)
+	if err != nil {
 		fatalf("assimilate failed: %v", err)
 	}
+	if err := skynet.AddLabeledNode(st, *name, *capacity, labels); err != nil {
+		fatalf("assimilate failed: %v", err)
+	}
+	if *priority != 0 {
+		if _, err := skynet.SetNodePriority(st, *name, *priority); err != nil {
+			fatalf("assimilate failed: %v", err)
+		}
+	}
+}
+
+func runNode(args []string, st *skynet.State) {
+	if len(args) == 0 {
+		fatalf("node requires a subcommand: rm, drain, resize, rename, label, priority or repair")
+	}
+	sub := strings.ToLower(args[0])
+	fs := flag.NewFlagSet("node "+sub, flag.ContinueOnError)
+
+	switch sub {
+	case "rm", "remove":
+		force := fs.Bool("force", false, "remove even with deployed units (they are written off)")
+		name := nodeArgs(fs, sub, args[1:], 1)[0]
+		node, err := skynet.RemoveNode(st, name, *force)
+		if err != nil {
+			fatalf("node rm failed: %v", err)
+		}
+		fmt.Printf("Node %s removed. written_off=%d total_nodes=%d available=%d\n", node.Name, node.Deployed, len(st.Nodes), skynet.AvailableCapacity(st.Nodes))
+	case "drain":
+		cancel := fs.Bool("cancel", false, "return a draining node to service")
+		name := nodeArgs(fs, sub, args[1:], 1)[0]
+		node, err := skynet.DrainNode(st, name, !*cancel)
+		if err != nil {
+			fatalf("node drain failed: %v", err)
+		}
+		fmt.Printf("Node %s status=%s deployed=%d available=%d\n", node.Name, skynet.NodeStatus(node), node.Deployed, skynet.AvailableCapacity(st.Nodes))
+	case "resize":
+		capacity := fs.Int("capacity", 0, "new node capacity")
+		force := fs.Bool("force", false, "allow shrinking below deployed units (excess is written off)")
+		name := nodeArgs(fs, sub, args[1:], 1)[0]
+		writtenOff, err := skynet.ResizeNode(st, name, *capacity, *force)
+		if err != nil {
+			fatalf("node resize failed: %v", err)
+		}
+		fmt.Printf("Node %s resized. capacity=%d written_off=%d total_capacity=%d available=%d\n", name, *capacity, writtenOff, skynet.TotalCapacity(st.Nodes), skynet.AvailableCapacity(st.Nodes))
+	case "rename", "mv":
+		names := nodeArgs(fs, sub, args[1:], 2)
+		if err := skynet.RenameNode(st, names[0], names[1]); err != nil {
+			fatalf("node rename failed: %v", err)
+		}
+		fmt.Printf("Node %s renamed to %s.\n", names[0], names[1])
+	case "priority":
+		positional := parseInterspersed(fs, args[1:])
+		if len(positional) != 2 {
+			fatalf("node priority requires a node NAME and a PRIORITY")
+		}
+		priority, err := strconv.Atoi(positional[1])
+		if err != nil {
+			fatalf("node priority: invalid priority %q", positional[1])
+		}
+		node, err := skynet.SetNodePriority(st, positional[0], priority)
+		if err != nil {
+			fatalf("node priority failed: %v", err)
+		}
+		fmt.Printf("Node %s priority=%d\n", node.Name, node.Priority)
+	case "repair":
+		positional := parseInterspersed(fs, args[1:])
+		if len(positional) != 2 {
+			fatalf("node repair requires a node NAME and a RATE (units per tick, or default)")
+		}
+		var rate *int
+		if !strings.EqualFold(positional[1], "default") {
+			r, err := strconv.Atoi(positional[1])
+			if err != nil {
+				fatalf("node repair: invalid rate %q", positional[1])
+			}
+			rate = &r
+		}
+		node, err := skynet.SetNodeRepairRate(st, positional[0], rate)
+		if err != nil {
+			fatalf("node repair failed: %v", err)
+		}
+		if node.RepairRate == nil {
+			fmt.Printf("Node %s repair_rate=default\n", node.Name)
+		} else {
+			fmt.Printf("Node %s repair_rate=%d\n", node.Name, *node.RepairRate)
+		}
+	case "label":
+		positional := parseInterspersed(fs, args[1:])
+		if len(positional) < 2 {
+			fatalf("node label requires a node NAME and key=value or key- arguments")
+		}
+		set := map[string]string{}
+		var remove []string
+		for _, arg := range positional[1:] {
+			if key, ok := strings.CutSuffix(arg, "-"); ok && !strings.Contains(arg, "=") {
+				remove = append(remove, key)
+				continue
+			}
+			labels, err := skynet.ParseLabels(arg)
+			if err != nil {
+				fatalf("node label failed: %v", err)
+			}
+			for k, v := range labels {
+				set[k] = v
+			}
+		}
+		node, err := skynet.LabelNode(st, positional[0], set, remove)
+		if err != nil {
+			fatalf("node label failed: %v", err)
+		}
+		fmt.Printf("Node %s labels=%s\n", node.Name, skynet.FormatLabels(node.Labels))
+	default:
+		fatalf("unknown node subcommand %q", sub)
+	}
+}
+
+func nodeArgs(fs *flag.FlagSet, sub string, args []string, n int) []string {
+	names := parseInterspersed(fs, args)
+	if len(names) != n {
+		if n == 2 {
+			fatalf("node %s requires OLD and NEW names", sub)
+		}
+		fatalf("node %s requires a node NAME", sub)
+	}
+	return names
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments and returns the positionals.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		mustParse(fs, args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
 
 func runTarget(args []string, st *skynet.State) {
-	fs := flag.NewFlagSet("target", flag.ExitOnError)
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		runTargetLifecycle(args, st)
+		return
+	}
+	fs := flag.NewFlagSet("target", flag.ContinueOnError)
 	name := fs.String("name", "", "target name")
 	threat := fs.Int("threat", 5, "threat score 1-10")
 	mustParse(fs, args)
 	if err := skynet.AddTarget(st, *name, *threat); err != nil {
 		fatalf("target failed: %v", err)
 	}
+	fmt.Printf("Target registry updated. total_targets=%d\n", len(st.Targets))
+}
+
+func runTargetLifecycle(args []string, st *skynet.State) {
+	sub := strings.ToLower(args[0])
+	fs := flag.NewFlagSet("target "+sub, flag.ContinueOnError)
+	switch sub {
+	case "rm", "remove":
+		force := fs.Bool("force", false, "remove even if missions reference the target")
+		name := targetArg(fs, sub, args[1:])
+		target, err := skynet.RemoveTarget(st, name, *force)
+		if err != nil {
+			fatalf("target rm failed: %v", err)
+		}
+		fmt.Printf("Target %s removed.\n", target.Name)
+	case "archive":
+		restore := fs.Bool("restore", false, "return an archived target to the active registry")
+		name := targetArg(fs, sub, args[1:])
+		target, err := skynet.ArchiveTarget(st, name, !*restore)
+		if err != nil {
+			fatalf("target archive failed: %v", err)
+		}
+		fmt.Printf("Target %s status=%s\n", target.Name, skynet.TargetStatus(target))
+	default:
+		fatalf("unknown target subcommand %q (use rm, archive or show, or -name/-threat to register)", sub)
+	}
+}
+
+func runTargetShow(args []string, st skynet.State) {
+	fs := flag.NewFlagSet("target show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	name := targetArg(fs, "show", args)
+	target, err := skynet.LookupTarget(st, name)
+	if err != nil {
+		fatalf("target show failed: %v", err)
+	}
+	if *asJSON {
+		writeJSON(target)
+		return
+	}
+	fmt.Printf("TARGET: %s\n", target.Name)
+	fmt.Printf("THREAT: %d\n", target.Threat)
+	fmt.Printf("STATUS: %s\n", skynet.TargetStatus(target))
+	fmt.Printf("CREATED: %s\n", target.CreatedAt)
+	fmt.Printf("UPDATED: %s\n", target.UpdatedAt)
+	fmt.Printf("MISSIONS: %d\n", len(skynet.TargetMissions(st, target.Name)))
+	fmt.Printf("THREAT HISTORY: %d\n", len(target.ThreatHistory))
+	prev := 0
+	for _, c := range target.ThreatHistory {
+		delta := ""
+		if prev > 0 {
+			delta = fmt.Sprintf(" (%+d)", c.Threat-prev)
+		}
+		fmt.Printf("  - %s threat=%d%s %s\n", c.At, c.Threat, delta, c.Reason)
+		prev = c.Threat
+	}
+}
+
+func selectorOrDie(spec string) skynet.Selector {
+	sel, err := skynet.ParseSelector(spec)
+	if err != nil {
+		fatalf("invalid selector: %v", err)
+	}
+	return sel
 }
 
-func runDispatch(args []string, st *skynet.State) skynet.Mission {
-	fs := flag.NewFlagSet("dispatch", flag.ExitOnError)
+func targetArg(fs *flag.FlagSet, sub string, args []string) string {
+	names := parseInterspersed(fs, args)
+	if len(names) != 1 {
+		fatalf("target %s requires a target NAME", sub)
+	}
+	return names[0]
+}
+
+// runDispatch dispatches a mission, or with -dry-run prints a preview and
+// reports false so nothing is saved.
+func runDispatch(args []string, st *skynet.State, cfg skynet.Config) (skynet.Mission, bool) {
+	fs := flag.NewFlagSet("dispatch", flag.ContinueOnError)
 	target := fs.String("target", "", "target name")
 	units := fs.Int("units", 1, "units to deploy")
+	selector := fs.String("selector", "", "only use nodes matching labels, e.g. region=east,role=air")
+	strategy := fs.String("strategy", cfg.Strategy, "allocation strategy: "+strings.Join(skynet.StrategyNames(), ", "))
+	partial := fs.Bool("partial", false, "deploy the available units when capacity is short instead of failing")
+	dryRun := fs.Bool("dry-run", false, "preview the mission without deploying units or saving")
+	jsonOutput := fs.Bool("json", false, "print the dry-run preview as JSON")
 	mustParse(fs, args)
-	mission, err := skynet.Dispatch(st, *target, *units)
+	if *jsonOutput && !*dryRun {
+		fatalf("dispatch -json requires -dry-run")
+	}
+	risk, err := cfg.Risk.Build()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	opts := skynet.DispatchOptions{
+		Selector:  selectorOrDie(*selector),
+		Strategy:  *strategy,
+		Threat:    cfg.ThreatDynamics,
+		Partial:   *partial,
+		Lifecycle: cfg.Lifecycle,
+		Risk:      risk,
+	}
+
+	if *dryRun {
+		preview, err := skynet.PreviewDispatch(*st, *target, *units, opts)
+		if err != nil {
+			fatalf("dispatch failed: %v", err)
+		}
+		if *jsonOutput {
+			writeJSON(preview)
+		} else {
+			printDispatchPreview(*st, preview)
+		}
+		return preview.Mission, false
+	}
+
+	mission, err := skynet.DispatchWith(st, *target, *units, opts)
 	if err != nil {
 		fatalf("dispatch failed: %v", err)
 	}
-	return mission
+	return mission, true
+}
+
+func printDispatchPreview(st skynet.State, p skynet.DispatchPreview) {
+	m := p.Mission
+	fmt.Printf("DRY RUN: mission -> %s | risk=%d | outcome=%s | consumed=%d recovered=%d net_loss=%d | available=%d->%d\n", m.Target, m.RiskScore, m.Outcome, m.Consumed, m.Recovered, m.NetLoss, p.AvailableBefore, p.AvailableAfter)
+	printShortfall(m)
+	printPhase(m)
+	printRiskModel(m)
+	fmt.Printf("STRATEGY: %s\n", m.Strategy)
+	if m.Selector != "" {
+		fmt.Printf("SELECTOR: %s\n", m.Selector)
+	}
+	if len(m.Assignments) > 0 {
+		fmt.Printf("NODES: %d\n", len(m.Assignments))
+		for _, a := range m.Assignments {
+			fmt.Printf("  - %s consumed=%d recovered=%d net_loss=%d\n", a.Node, a.Consumed, a.Recovered, a.NetLoss)
+		}
+	}
+	if before, err := skynet.LookupTarget(st, m.Target); err == nil && before.Threat != p.Target.Threat {
+		fmt.Printf("Target %s threat %d -> %d\n", p.Target.Name, before.Threat, p.Target.Threat)
+	}
+	fmt.Println("No changes were saved.")
 }
 
 func runGameplan(args []string, st skynet.State) {
-	fs := flag.NewFlagSet("gameplan", flag.ExitOnError)
+	fs := flag.NewFlagSet("gameplan", flag.ContinueOnError)
 	budget := fs.Int("budget", -1, "defense budget in units (default: current available capacity)")
 	beta := fs.Float64("beta", 1.2, "attacker rationality (higher means more greedy)")
 	jsonOutput := fs.Bool("json", false, "print JSON output")
+	selector := fs.String("selector", "", "budget from nodes matching labels, e.g. region=east")
 	mustParse(fs, args)
 
-	available := skynet.AvailableCapacity(st.Nodes)
+	available := skynet.AvailableCapacity(skynet.SelectNodes(st.Nodes, selectorOrDie(*selector)))
 	effectiveBudget := *budget
 	if effectiveBudget < 0 {
 		effectiveBudget = available
@@ -126,22 +505,24 @@ func runGameplan(args []string, st skynet.State) {
 	}
 }
 
-func runWargame(args []string, st skynet.State) {
-	fs := flag.NewFlagSet("wargame", flag.ExitOnError)
+func runWargame(args []string, st skynet.State, defaultSeed int64) {
+	fs := flag.NewFlagSet("wargame", flag.ContinueOnError)
 	rounds := fs.Int("rounds", 200, "simulation rounds")
 	budget := fs.Int("budget", -1, "defense budget in units (default: current available capacity)")
 	beta := fs.Float64("beta", 1.2, "attacker rationality (higher means more greedy)")
-	seed := fs.Int64("seed", 42, "random seed")
+	seed := fs.Int64("seed", defaultSeed, "random seed (default: the global -seed, else 42)")
 	jsonOutput := fs.Bool("json", false, "print JSON output")
+	selector := fs.String("selector", "", "budget from nodes matching labels, e.g. region=east")
 	mustParse(fs, args)
 
-	available := skynet.AvailableCapacity(st.Nodes)
+	available := skynet.AvailableCapacity(skynet.SelectNodes(st.Nodes, selectorOrDie(*selector)))
 	effectiveBudget := *budget
 	if effectiveBudget < 0 {
 		effectiveBudget = available
 	}
 
-	result, err := skynet.RunWarGame(st, *rounds, effectiveBudget, *beta, *seed)
+	st.SetEngine(skynet.NewEngine(st.Engine().Clock, *seed))
+	result, err := skynet.PlayWarGame(st, *rounds, effectiveBudget, *beta)
 	if err != nil {
 		fatalf("wargame failed: %v", err)
 	}
@@ -157,12 +538,17 @@ func runWargame(args []string, st skynet.State) {
 	}
 }
 
-func runReport(args []string, st skynet.State) {
-	fs := flag.NewFlagSet("report", flag.ExitOnError)
+func runReport(args []string, st skynet.State, archive skynet.MissionArchive) {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
 	last := fs.Int("last", 0, "analyze only last N missions (0 means all)")
+	includeArchive := fs.Bool("archive", false, "include archived missions")
 	jsonOutput := fs.Bool("json", false, "print JSON output")
 	mustParse(fs, args)
 
+	if *includeArchive {
+		st = withArchiveOrDie(st, archive)
+	}
+
 	report, err := skynet.BuildMissionReport(st, *last)
 	if err != nil {
 		fatalf("report failed: %v", err)
@@ -174,15 +560,181 @@ func runReport(args []string, st skynet.State) {
 
 	fmt.Printf("REPORT: analyzed=%d/%d success=%d failed=%d success_rate=%.2f avg_risk=%.2f avg_net_loss=%.2f\n", report.AnalyzedMissions, report.TotalMissions, report.SuccessfulMissions, report.FailedMissions, report.SuccessRate, report.AverageRisk, report.AverageNetLoss)
 	fmt.Printf("RESOURCES: consumed=%d recovered=%d net_loss=%d\n", report.TotalConsumed, report.TotalRecovered, report.TotalNetLoss)
+	if report.UnderStrength > 0 {
+		fmt.Printf("UNDER-STRENGTH: missions=%d shortfall=%d\n", report.UnderStrength, report.TotalShortfall)
+	}
+	if report.InFlight > 0 {
+		fmt.Printf("IN FLIGHT: missions=%d (net loss not final)\n", report.InFlight)
+	}
 	if report.MostTargeted != "" {
 		fmt.Printf("MOST TARGETED: %s (%d)\n", report.MostTargeted, report.MostTargetedCount)
 	}
 	if report.LastMissionAt != "" {
 		fmt.Printf("LAST MISSION AT: %s\n", report.LastMissionAt)
 	}
+	if len(report.NodeLosses) > 0 {
+		fmt.Println("NODE LOSSES:")
+		for _, nl := range report.NodeLosses {
+			fmt.Printf("  - %s missions=%d consumed=%d recovered=%d net_loss=%d share=%.2f\n", nl.Node, nl.Missions, nl.Consumed, nl.Recovered, nl.NetLoss, nl.Share)
+		}
+	}
+	if report.UnattributedNetLoss > 0 {
+		fmt.Printf("UNATTRIBUTED NET LOSS: %d (missions without a node ledger)\n", report.UnattributedNetLoss)
+	}
+}
+
+// runMission handles the mission subcommands and reports whether the state
+// was changed.
+func runMission(args []string, state *skynet.State, archive skynet.MissionArchive) bool {
+	if len(args) == 0 {
+		fatalf("mission requires a subcommand: list, show, recall or abort")
+	}
+	st := *state
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "list", "ls":
+		fs := flag.NewFlagSet("mission list", flag.ContinueOnError)
+		last := fs.Int("last", 0, "show only last N missions (0 means all)")
+		includeArchive := fs.Bool("archive", false, "include archived missions")
+		jsonOutput := fs.Bool("json", false, "print JSON output")
+		mustParse(fs, args)
+
+		if *includeArchive {
+			st = withArchiveOrDie(st, archive)
+		}
+		missions := st.Missions
+		if *last > 0 && *last < len(missions) {
+			missions = missions[len(missions)-*last:]
+		}
+		if *jsonOutput {
+			writeJSON(missions)
+			return false
+		}
+		fmt.Printf("MISSIONS: %d\n", len(missions))
+		for _, m := range missions {
+			fmt.Printf("  - %s %s target=%s units=%d consumed=%d recovered=%d net_loss=%d risk=%d outcome=%s", m.ID, m.CreatedAt, m.Target, m.Units, m.Consumed, m.Recovered, m.NetLoss, m.RiskScore, m.Outcome)
+			if m.Strategy != "" {
+				fmt.Printf(" strategy=%s", m.Strategy)
+			}
+			if m.UnderStrength() {
+				fmt.Printf(" shortfall=%d", m.Shortfall)
+			}
+			if m.Status != "" {
+				fmt.Printf(" status=%s", m.Status)
+			}
+			if m.Phase != "" {
+				fmt.Printf(" phase=%s", m.Phase)
+			}
+			fmt.Println()
+		}
+	case "show":
+		fs := flag.NewFlagSet("mission show", flag.ContinueOnError)
+		jsonOutput := fs.Bool("json", false, "print JSON output")
+		ids := parseInterspersed(fs, args)
+		if len(ids) != 1 {
+			fatalf("mission show requires a mission ID")
+		}
+		mission, err := skynet.LookupMission(st, ids[0])
+		if err != nil {
+			// Fall back to the archive only when the hot state misses.
+			mission, err = skynet.LookupMission(withArchiveOrDie(st, archive), ids[0])
+		}
+		if err != nil {
+			fatalf("mission show failed: %v", err)
+		}
+		if *jsonOutput {
+			writeJSON(mission)
+			return false
+		}
+		printMission(mission)
+	case "recall", "abort":
+		fs := flag.NewFlagSet("mission "+sub, flag.ContinueOnError)
+		ids := parseInterspersed(fs, args)
+		if len(ids) != 1 {
+			fatalf("mission %s requires a mission ID", sub)
+		}
+		settle := skynet.RecallMission
+		if sub == "abort" {
+			settle = skynet.AbortMission
+		}
+		mission, returned, err := settle(state, ids[0])
+		if err != nil {
+			fatalf("mission %s failed: %v", sub, err)
+		}
+		fmt.Printf("Mission %s %s: returned=%d recovered=%d net_loss=%d | available=%d\n", mission.ID, mission.Status, returned, mission.Recovered, mission.NetLoss, skynet.AvailableCapacity(state.Nodes))
+		return true
+	default:
+		fatalf("unknown mission subcommand %q", sub)
+	}
+	return false
+}
+
+func runArchive(args []string, st *skynet.State, archive skynet.MissionArchive, policy skynet.RetentionPolicy) {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	keep := fs.Int("keep", policy.KeepMissions, "keep only the newest N missions in the hot state (0 means no limit)")
+	days := fs.Int("days", policy.KeepDays, "keep only missions from the last N days in the hot state (0 means no limit)")
+	mustParse(fs, args)
+
+	moved, err := skynet.RotateMissions(st, archive, skynet.RetentionPolicy{KeepMissions: *keep, KeepDays: *days}, st.Now())
+	if err != nil {
+		fatalf("archive failed: %v", err)
+	}
+	fmt.Printf("Archived %d mission(s). hot_missions=%d archive=%s\n", moved, len(st.Missions), archive.Dir)
 }
 
-func runStatus(st skynet.State, path string) {
+func printMission(m skynet.Mission) {
+	fmt.Printf("MISSION: %s\n", m.ID)
+	fmt.Printf("TARGET: %s\n", m.Target)
+	fmt.Printf("CREATED: %s\n", m.CreatedAt)
+	fmt.Printf("OUTCOME: %s risk=%d\n", m.Outcome, m.RiskScore)
+	printRiskModel(m)
+	fmt.Printf("UNITS: requested=%d consumed=%d recovered=%d net_loss=%d\n", m.Units, m.Consumed, m.Recovered, m.NetLoss)
+	printShortfall(m)
+	if m.Status != "" {
+		fmt.Printf("STATUS: %s\n", m.Status)
+	}
+	for _, a := range m.Actions {
+		fmt.Printf("  %s %s units=%d\n", a.At, a.Action, a.Units)
+	}
+	if m.Phase != "" {
+		fmt.Printf("PHASE: %s", m.Phase)
+		if m.InFlight() {
+			fmt.Printf(" until_tick=%d", m.PhaseUntil)
+		}
+		fmt.Println()
+		for _, c := range m.Timeline {
+			fmt.Printf("  %s tick=%d %s\n", c.At, c.Tick, c.Phase)
+		}
+	}
+	if m.Strategy != "" {
+		fmt.Printf("STRATEGY: %s\n", m.Strategy)
+	}
+	if m.Selector != "" {
+		fmt.Printf("SELECTOR: %s\n", m.Selector)
+	}
+	if len(m.Assignments) == 0 {
+		if m.Consumed > 0 {
+			fmt.Println("NODES: (no ledger recorded)")
+		}
+		return
+	}
+	fmt.Printf("NODES: %d\n", len(m.Assignments))
+	for _, a := range m.Assignments {
+		fmt.Printf("  - %s consumed=%d recovered=%d net_loss=%d\n", a.Node, a.Consumed, a.Recovered, a.NetLoss)
+	}
+}
+
+func withArchiveOrDie(st skynet.State, archive skynet.MissionArchive) skynet.State {
+	all, err := skynet.IncludeArchived(st, archive)
+	if err != nil {
+		fatalf("failed to read mission archive: %v", err)
+	}
+	return all
+}
+
+func runStatus(st skynet.State, path, workspace string) {
 	state := "OFFLINE"
 	if st.Core.Online {
 		state = "ONLINE"
@@ -195,13 +747,23 @@ func runStatus(st skynet.State, path string) {
 	if st.Core.LastMission != "" {
 		fmt.Printf("LAST MISSION: %s\n", st.Core.LastMission)
 	}
+	if st.Simulated() {
+		fmt.Printf("SIM CLOCK: %s | ticks=%d\n", st.Core.SimTime, st.Core.Ticks)
+	}
 
 	fmt.Printf("NODES: %d | TOTAL CAPACITY: %d | AVAILABLE: %d\n", len(st.Nodes), skynet.TotalCapacity(st.Nodes), skynet.AvailableCapacity(st.Nodes))
 	if len(st.Nodes) > 0 {
 		nodes := append([]skynet.Node(nil), st.Nodes...)
 		sort.Slice(nodes, func(i, j int) bool { return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name) })
 		for _, n := range nodes {
-			fmt.Printf("  - %s cap=%d deployed=%d available=%d joined=%s\n", n.Name, n.Capacity, n.Deployed, skynet.NodeAvailable(n), n.JoinedAt)
+			fmt.Printf("  - %s cap=%d deployed=%d available=%d status=%s joined=%s", n.Name, n.Capacity, n.Deployed, skynet.NodeAvailable(n), skynet.NodeStatus(n), n.JoinedAt)
+			if len(n.Labels) > 0 {
+				fmt.Printf(" labels=%s", skynet.FormatLabels(n.Labels))
+			}
+			if n.Priority != 0 {
+				fmt.Printf(" priority=%d", n.Priority)
+			}
+			fmt.Println()
 		}
 	}
 
@@ -210,7 +772,7 @@ func runStatus(st skynet.State, path string) {
 		targets := append([]skynet.Target(nil), st.Targets...)
 		sort.Slice(targets, func(i, j int) bool { return strings.ToLower(targets[i].Name) < strings.ToLower(targets[j].Name) })
 		for _, t := range targets {
-			fmt.Printf("  - %s threat=%d\n", t.Name, t.Threat)
+			fmt.Printf("  - %s threat=%d status=%s updated=%s\n", t.Name, t.Threat, skynet.TargetStatus(t), t.UpdatedAt)
 		}
 	}
 
@@ -219,13 +781,710 @@ func runStatus(st skynet.State, path string) {
 		last := st.Missions[len(st.Missions)-1]
 		fmt.Printf("  - latest %s target=%s units=%d consumed=%d recovered=%d net_loss=%d risk=%d outcome=%s\n", last.ID, last.Target, last.Units, last.Consumed, last.Recovered, last.NetLoss, last.RiskScore, last.Outcome)
 	}
+	if active := skynet.ActiveMissions(st); len(active) > 0 {
+		fmt.Printf("ACTIVE MISSIONS: %d\n", len(active))
+		for _, m := range active {
+			fmt.Printf("  - %s target=%s phase=%s until_tick=%d locked=%d\n", m.ID, m.Target, m.Phase, m.PhaseUntil, m.NetLoss)
+		}
+	}
 
+	if workspace != "" {
+		fmt.Printf("WORKSPACE: %s\n", workspace)
+	}
 	fmt.Printf("STATE PATH: %s\n", path)
 }
 
+func runMigrate(args []string, store skynet.Store, st skynet.State) {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	check := fs.Bool("check", false, "report pending migrations without writing")
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args)
+
+	plan, err := store.PlanMigration()
+	if err != nil {
+		fatalf("migrate failed: %v", err)
+	}
+	if !*check && plan.NeedsMigration() {
+		saveOrDie(store, st)
+	}
+	if *jsonOutput {
+		writeJSON(plan)
+		return
+	}
+
+	if !plan.Exists {
+		fmt.Printf("MIGRATE: no state file at %s (new state uses schema v%d)\n", plan.Path, plan.ToVersion)
+		return
+	}
+	if !plan.NeedsMigration() {
+		fmt.Printf("MIGRATE: %s is up to date (schema v%d)\n", plan.Path, plan.ToVersion)
+		return
+	}
+	verb := "migrated"
+	if *check {
+		verb = "would migrate"
+	}
+	fmt.Printf("MIGRATE: %s %s schema v%d -> v%d\n", verb, plan.Path, plan.FromVersion, plan.ToVersion)
+	for _, step := range plan.Steps {
+		fmt.Printf("  - v%d -> v%d: %s\n", step.From, step.To, step.Description)
+	}
+	for _, change := range plan.Changes {
+		fmt.Printf("    %s\n", change)
+	}
+}
+
+func runJournal(args []string, path string, key *skynet.StateKey, st skynet.State) {
+	if len(args) == 0 {
+		fatalf("journal requires a subcommand: init, log or replay")
+	}
+	journal := skynet.NewJournalStore(path)
+	journal.Key = key
+	sub := strings.ToLower(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "init":
+		fs := flag.NewFlagSet("journal init", flag.ContinueOnError)
+		mustParse(fs, args)
+		events, err := journal.Events()
+		if err != nil {
+			fatalf("journal init failed: %v", err)
+		}
+		if len(events) > 0 {
+			fatalf("journal already exists at %s", journal.JournalPath())
+		}
+		saveOrDie(journal, st)
+		fmt.Printf("Journal initialized from current state. journal=%s\n", journal.JournalPath())
+		fmt.Printf("Commands now use the journal backend; %s is no longer updated.\n", path)
+	case "log":
+		fs := flag.NewFlagSet("journal log", flag.ContinueOnError)
+		last := fs.Int("last", 0, "show only last N events (0 means all)")
+		jsonOutput := fs.Bool("json", false, "print JSON output")
+		mustParse(fs, args)
+		events, err := journal.Events()
+		if err != nil {
+			fatalf("journal log failed: %v", err)
+		}
+		if *last > 0 && *last < len(events) {
+			events = events[len(events)-*last:]
+		}
+		if *jsonOutput {
+			writeJSON(events)
+			return
+		}
+		fmt.Printf("JOURNAL: %s events=%d\n", journal.JournalPath(), len(events))
+		for _, ev := range events {
+			fmt.Printf("  #%d %s %s %s\n", ev.Seq, ev.At, ev.Type, ev.Describe())
+		}
+	case "replay":
+		fs := flag.NewFlagSet("journal replay", flag.ContinueOnError)
+		at := fs.String("at", "", "reconstruct state as of this RFC3339 time")
+		seq := fs.Int("seq", 0, "reconstruct state after this event number")
+		jsonOutput := fs.Bool("json", false, "print JSON output")
+		mustParse(fs, args)
+
+		var replayed skynet.State
+		var err error
+		switch {
+		case *at != "" && *seq > 0:
+			fatalf("journal replay accepts only one of -at and -seq")
+		case *at != "":
+			t, perr := time.Parse(time.RFC3339, *at)
+			if perr != nil {
+				fatalf("invalid -at %q: %v", *at, perr)
+			}
+			replayed, err = journal.LoadAt(t)
+		case *seq > 0:
+			replayed, err = journal.LoadSeq(*seq)
+		default:
+			replayed, err = journal.Load()
+		}
+		if err != nil {
+			fatalf("journal replay failed: %v", err)
+		}
+		if *jsonOutput {
+			writeJSON(replayed)
+			return
+		}
+		runStatus(replayed, journal.JournalPath(), "")
+	default:
+		fatalf("unknown journal subcommand %q", sub)
+	}
+}
+
+func runHistory(args []string, history skynet.HistoryStore) {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	last := fs.Int("last", 0, "show only last N revisions (0 means all)")
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args)
+
+	log, err := history.History()
+	if err != nil {
+		fatalf("history failed: %v", err)
+	}
+	revisions := log.Revisions
+	if *last > 0 && *last < len(revisions) {
+		revisions = revisions[len(revisions)-*last:]
+	}
+	if *jsonOutput {
+		log.Revisions = revisions
+		writeJSON(log)
+		return
+	}
+
+	fmt.Printf("HISTORY: revisions=%d current=%d\n", len(log.Revisions), log.Cursor)
+	for _, rev := range revisions {
+		marker := " "
+		if rev.ID == log.Cursor {
+			marker = "*"
+		}
+		fmt.Printf(" %s %d %s %s\n", marker, rev.ID, rev.At, rev.Command)
+	}
+}
+
+func runUndoRedo(name string, args []string, history skynet.HistoryStore) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	steps := fs.Int("n", 1, "number of revisions to step")
+	mustParse(fs, args)
+
+	move := history.Undo
+	if name == "redo" {
+		move = history.Redo
+	}
+	rev, err := move(*steps)
+	if err != nil {
+		fatalf("%s failed: %v", name, err)
+	}
+	fmt.Printf("Restored revision %d (%s) from %s\n", rev.ID, rev.Command, rev.At)
+}
+
+func runDiff(args []string, home, workspace string, key *skynet.StateKey) {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args)
+	refs := fs.Args()
+	if len(refs) != 2 {
+		fatalf("diff requires two states: FILE, WORKSPACE, rev:N or current")
+	}
+
+	a, err := skynet.LoadStateRef(home, workspace, refs[0], key)
+	if err != nil {
+		fatalf("diff failed: %v", err)
+	}
+	b, err := skynet.LoadStateRef(home, workspace, refs[1], key)
+	if err != nil {
+		fatalf("diff failed: %v", err)
+	}
+	diff := skynet.DiffStates(a, b)
+	if *jsonOutput {
+		writeJSON(diff)
+		return
+	}
+
+	fmt.Printf("DIFF: %s -> %s\n", refs[0], refs[1])
+	if diff.Empty() {
+		fmt.Println("  no differences")
+		return
+	}
+	for _, c := range diff.Core {
+		fmt.Printf("~ core %s: %s -> %s\n", c.Field, c.Before, c.After)
+	}
+	for _, n := range diff.NodesAdded {
+		fmt.Printf("+ node %s cap=%d deployed=%d\n", n.Name, n.Capacity, n.Deployed)
+	}
+	for _, n := range diff.NodesRemoved {
+		fmt.Printf("- node %s cap=%d deployed=%d\n", n.Name, n.Capacity, n.Deployed)
+	}
+	for _, c := range diff.NodesChanged {
+		fmt.Printf("~ node %s cap=%d->%d deployed=%d->%d (%+d)", c.Name, c.CapacityBefore, c.CapacityAfter, c.DeployedBefore, c.DeployedAfter, c.DeployedDelta)
+		if c.StatusBefore != c.StatusAfter {
+			fmt.Printf(" status=%s->%s", c.StatusBefore, c.StatusAfter)
+		}
+		if c.LabelsBefore != c.LabelsAfter {
+			fmt.Printf(" labels=%q->%q", c.LabelsBefore, c.LabelsAfter)
+		}
+		if c.PriorityBefore != c.PriorityAfter {
+			fmt.Printf(" priority=%d->%d", c.PriorityBefore, c.PriorityAfter)
+		}
+		fmt.Println()
+	}
+	for _, t := range diff.TargetsAdded {
+		fmt.Printf("+ target %s threat=%d\n", t.Name, t.Threat)
+	}
+	for _, t := range diff.TargetsRemoved {
+		fmt.Printf("- target %s threat=%d\n", t.Name, t.Threat)
+	}
+	for _, c := range diff.TargetsChanged {
+		fmt.Printf("~ target %s threat=%d->%d", c.Name, c.ThreatBefore, c.ThreatAfter)
+		if c.StatusBefore != c.StatusAfter {
+			fmt.Printf(" status=%s->%s", c.StatusBefore, c.StatusAfter)
+		}
+		fmt.Println()
+	}
+	for _, m := range diff.MissionsAdded {
+		fmt.Printf("+ mission %s target=%s units=%d outcome=%s net_loss=%d\n", m.ID, m.Target, m.Units, m.Outcome, m.NetLoss)
+	}
+	for _, m := range diff.MissionsRemoved {
+		fmt.Printf("- mission %s target=%s units=%d outcome=%s net_loss=%d\n", m.ID, m.Target, m.Units, m.Outcome, m.NetLoss)
+	}
+}
+
+func runExport(args []string, st skynet.State, workspace string) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "output bundle path (- for stdout)")
+	mustParse(fs, args)
+	if *output == "" {
+		fatalf("export requires -o PATH")
+	}
+
+	bundle, err := skynet.NewBundle(st, workspace)
+	if err != nil {
+		fatalf("export failed: %v", err)
+	}
+	if *output == "-" {
+		if err := skynet.WriteBundle(os.Stdout, bundle); err != nil {
+			fatalf("export failed: %v", err)
+		}
+		return
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		fatalf("export failed: %v", err)
+	}
+	if err := skynet.WriteBundle(f, bundle); err != nil {
+		f.Close()
+		fatalf("export failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("export failed: %v", err)
+	}
+	fmt.Printf("Exported %s: nodes=%d targets=%d missions=%d checksum=%s\n", *output, bundle.Metadata.Nodes, bundle.Metadata.Targets, bundle.Metadata.Missions, bundle.Checksum)
+}
+
+func printShortfall(m skynet.Mission) {
+	if m.UnderStrength() {
+		fmt.Printf("UNDER-STRENGTH: requested=%d deployed=%d shortfall=%d\n", m.Units, m.Units-m.Shortfall, m.Shortfall)
+	}
+}
+
+func printRiskModel(m skynet.Mission) {
+	if m.RiskModel == "" {
+		return
+	}
+	keys := make([]string, 0, len(m.RiskParams))
+	for k := range m.RiskParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("RISK MODEL: %s", m.RiskModel)
+	for _, k := range keys {
+		fmt.Printf(" %s=%g", k, m.RiskParams[k])
+	}
+	fmt.Println()
+}
+
+func printPhase(m skynet.Mission) {
+	if m.InFlight() {
+		fmt.Printf("IN FLIGHT: phase=%s until_tick=%d locked=%d\n", m.Phase, m.PhaseUntil, m.NetLoss)
+	}
+}
+
+// printThreatShift reports a threat change made by threat dynamics after
+// the mission.
+func printThreatShift(st skynet.State, mission skynet.Mission) {
+	target, err := skynet.LookupTarget(st, mission.Target)
+	if err != nil || len(target.ThreatHistory) < 2 {
+		return
+	}
+	last := target.ThreatHistory[len(target.ThreatHistory)-1]
+	if last.At != mission.CreatedAt || (last.Reason != skynet.ThreatReasonEscalation && last.Reason != skynet.ThreatReasonNeutralized) {
+		return
+	}
+	prev := target.ThreatHistory[len(target.ThreatHistory)-2]
+	fmt.Printf("Target %s threat %d -> %d (%s)\n", target.Name, prev.Threat, last.Threat, last.Reason)
+}
+
+func runTick(args []string, st *skynet.State, cfg skynet.Config) {
+	fs := flag.NewFlagSet("tick", flag.ContinueOnError)
+	n := fs.Int("n", 1, "number of ticks to advance")
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args)
+	result, err := skynet.Tick(st, *n, cfg.Simulation, cfg.ThreatDynamics)
+	if err != nil {
+		fatalf("tick failed: %v", err)
+	}
+	if *jsonOutput {
+		writeJSON(result)
+		return
+	}
+	fmt.Printf("Clock advanced %d tick(s): %s -> %s | repaired=%d | available=%d\n", result.Ticks, result.From, result.To, result.TotalRepaired(), skynet.AvailableCapacity(st.Nodes))
+	names := make([]string, 0, len(result.Repaired))
+	for name := range result.Repaired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  - %s repaired=%d\n", name, result.Repaired[name])
+	}
+	for _, t := range result.Decayed {
+		fmt.Printf("  - target %s threat decayed to %d\n", t.Name, t.Threat)
+	}
+	for _, m := range result.Missions {
+		fmt.Printf("  - mission %s phase=%s recovered=%d net_loss=%d\n", m.ID, m.Phase, m.Recovered, m.NetLoss)
+	}
+}
+
+func runApply(args []string, st *skynet.State) bool {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	input := fs.String("f", "", "manifest path, JSON or CSV (- for stdin)")
+	format := fs.String("format", "", "manifest format: json or csv (default: from extension or content)")
+	prune := fs.Bool("prune", false, "remove nodes and targets missing from the manifest")
+	force := fs.Bool("force", false, "allow writing off deployed units and removing referenced targets")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	jsonOutput := fs.Bool("json", false, "print the plan as JSON")
+	mustParse(fs, args)
+	if *input == "" {
+		fatalf("apply requires -f PATH")
+	}
+
+	var manifest skynet.Manifest
+	var err error
+	switch {
+	case *input == "-":
+		data, readErr := io.ReadAll(os.Stdin)
+		if readErr != nil {
+			fatalf("apply failed: %v", readErr)
+		}
+		manifest, err = skynet.ParseManifest(data, strings.EqualFold(*format, "csv"))
+	case *format != "":
+		data, readErr := os.ReadFile(*input)
+		if readErr != nil {
+			fatalf("apply failed: %v", readErr)
+		}
+		manifest, err = skynet.ParseManifest(data, strings.EqualFold(*format, "csv"))
+	default:
+		manifest, err = skynet.ReadManifest(*input)
+	}
+	if err != nil {
+		fatalf("apply failed: %v", err)
+	}
+
+	opts := skynet.ApplyOptions{Prune: *prune, Force: *force}
+	var plan skynet.ApplyPlan
+	if *dryRun {
+		plan, err = skynet.PlanApply(*st, manifest, opts)
+	} else {
+		plan, err = skynet.Apply(st, manifest, opts)
+	}
+	if err != nil {
+		fatalf("apply failed: %v", err)
+	}
+	if *jsonOutput {
+		writeJSON(plan)
+	} else {
+		printApplyPlan(plan, *dryRun)
+	}
+	return !*dryRun && !plan.Empty()
+}
+
+func printApplyPlan(plan skynet.ApplyPlan, dryRun bool) {
+	verb := "APPLIED"
+	if dryRun {
+		verb = "PLAN"
+	}
+	fmt.Printf("%s: actions=%d unchanged=%d\n", verb, len(plan.Actions), plan.Unchanged)
+	symbols := map[string]string{skynet.ApplyCreate: "+", skynet.ApplyUpdate: "~", skynet.ApplyRemove: "-", skynet.ApplyDrain: "~", skynet.ApplyArchive: "~"}
+	for _, a := range plan.Actions {
+		fmt.Printf("%s %s %s %s", symbols[a.Op], a.Op, a.Kind, a.Name)
+		if len(a.Changes) > 0 {
+			fmt.Printf(" %s", strings.Join(a.Changes, " "))
+		}
+		fmt.Println()
+	}
+}
+
+func runImport(args []string, st *skynet.State) {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := fs.String("f", "", "bundle path (- for stdin)")
+	mode := fs.String("mode", "merge", "merge or replace")
+	onConflict := fs.String("on-conflict", "fail", "duplicate node/target names: fail, keep, overwrite or rename")
+	mustParse(fs, args)
+	if *input == "" {
+		fatalf("import requires -f PATH")
+	}
+	policy, err := skynet.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		fatalf("import failed: %v", err)
+	}
+
+	in := os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fatalf("import failed: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	bundle, err := skynet.ReadBundle(in)
+	if err != nil {
+		fatalf("import failed: %v", err)
+	}
+	imported, err := bundle.Decode()
+	if err != nil {
+		fatalf("import failed: %v", err)
+	}
+
+	switch strings.ToLower(*mode) {
+	case "replace":
+		*st = imported
+		fmt.Printf("Imported %s (replace): nodes=%d targets=%d missions=%d\n", *input, len(st.Nodes), len(st.Targets), len(st.Missions))
+	case "merge":
+		result, err := skynet.MergeState(st, imported, policy)
+		if err != nil {
+			fatalf("import failed: %v (use -on-conflict keep, overwrite or rename)", err)
+		}
+		fmt.Printf("Imported %s (merge): nodes +%d ~%d =%d | targets +%d ~%d =%d | missions +%d ~%d =%d\n", *input,
+			result.NodesAdded, result.NodesUpdated, result.NodesSkipped,
+			result.TargetsAdded, result.TargetsUpdated, result.TargetsSkipped,
+			result.MissionsAdded, result.MissionsUpdated, result.MissionsSkipped)
+		for _, id := range result.MissionConflicts {
+			fmt.Printf("  - mission ID %s is used by a different mission (resolved with -on-conflict %s)\n", id, policy)
+		}
+		for _, r := range result.Renamed {
+			fmt.Printf("  - renamed %s\n", r)
+		}
+	default:
+		fatalf("import failed: unknown mode %q (want merge or replace)", *mode)
+	}
+}
+
+func runWorkspace(args []string, home string, key *skynet.StateKey) {
+	if len(args) == 0 {
+		fatalf("workspace requires a subcommand: create, list, switch, delete or clone")
+	}
+	sub := strings.ToLower(args[0])
+	fs := flag.NewFlagSet("workspace "+sub, flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args[1:])
+	rest := fs.Args()
+
+	switch sub {
+	case "list", "ls":
+		workspaces, err := skynet.ListWorkspaces(home)
+		if err != nil {
+			fatalf("workspace list failed: %v", err)
+		}
+		if *jsonOutput {
+			writeJSON(workspaces)
+			return
+		}
+		for _, w := range workspaces {
+			marker := " "
+			if w.Active {
+				marker = "*"
+			}
+			fmt.Printf("%s %s %s\n", marker, w.Name, w.Path)
+		}
+	case "create":
+		name := workspaceArg(sub, rest, 1)[0]
+		if err := skynet.CreateWorkspace(home, name, key); err != nil {
+			fatalf("workspace create failed: %v", err)
+		}
+		fmt.Printf("Workspace %q created. state=%s\n", name, skynet.WorkspaceStatePath(home, name))
+	case "switch", "use":
+		name := workspaceArg(sub, rest, 1)[0]
+		if err := skynet.SwitchWorkspace(home, name); err != nil {
+			fatalf("workspace switch failed: %v", err)
+		}
+		fmt.Printf("Active workspace: %s\n", name)
+	case "delete", "rm":
+		name := workspaceArg(sub, rest, 1)[0]
+		if err := skynet.DeleteWorkspace(home, name); err != nil {
+			fatalf("workspace delete failed: %v", err)
+		}
+		fmt.Printf("Workspace %q deleted.\n", name)
+	case "clone":
+		names := workspaceArg(sub, rest, 2)
+		if err := skynet.CloneWorkspace(home, names[0], names[1], key); err != nil {
+			fatalf("workspace clone failed: %v", err)
+		}
+		fmt.Printf("Workspace %q cloned to %q. state=%s\n", names[0], names[1], skynet.WorkspaceStatePath(home, names[1]))
+	default:
+		fatalf("unknown workspace subcommand %q", sub)
+	}
+}
+
+func workspaceArg(sub string, args []string, n int) []string {
+	if len(args) != n {
+		if n == 2 {
+			fatalf("workspace %s requires SOURCE and NEW names", sub)
+		}
+		fatalf("workspace %s requires a workspace NAME", sub)
+	}
+	return args
+}
+
+func runFsck(args []string, store skynet.Store, st *skynet.State) {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "apply mechanical fixes and save; units deployed beyond capacity are written off")
+	jsonOutput := fs.Bool("json", false, "print JSON output")
+	mustParse(fs, args)
+
+	report := skynet.Fsck(st, *repair)
+	if report.Repaired > 0 {
+		saveOrDie(store, *st)
+	}
+	if *jsonOutput {
+		writeJSON(report)
+	} else {
+		fmt.Printf("FSCK: violations=%d errors=%d repaired=%d\n", len(report.Violations), report.Errors(), report.Repaired)
+		for _, v := range report.Violations {
+			note := ""
+			switch {
+			case v.Repaired:
+				note = " [repaired]"
+			case v.Repairable:
+				note = " [repairable with -repair]"
+			}
+			fmt.Printf("  %-22s %-7s %s: %s%s\n", v.Code, v.Severity, v.Subject, v.Message, note)
+		}
+	}
+	if report.Errors() > 0 {
+		exit(1)
+	}
+}
+
+func runRekey(args []string, path string, key *skynet.StateKey) {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	newKeyFile := fs.String("new-key-file", "", "new key file (default: SKYNET_NEW_PASSPHRASE or SKYNET_NEW_KEY_FILE)")
+	decrypt := fs.Bool("decrypt", false, "remove encryption and store plaintext")
+	mustParse(fs, args)
+
+	var newKey *skynet.StateKey
+	var err error
+	switch {
+	case *decrypt && *newKeyFile != "":
+		fatalf("rekey accepts only one of -decrypt and -new-key-file")
+	case *decrypt:
+	case *newKeyFile != "":
+		newKey, err = skynet.LoadStateKeyFile(*newKeyFile)
+	default:
+		newKey, err = skynet.StateKeyFromEnv("SKYNET_NEW_PASSPHRASE", "SKYNET_NEW_KEY_FILE")
+		if err == nil && newKey == nil {
+			fatalf("rekey requires a new key: -new-key-file, SKYNET_NEW_PASSPHRASE or SKYNET_NEW_KEY_FILE (or -decrypt)")
+		}
+	}
+	if err != nil {
+		fatalf("rekey failed: %v", err)
+	}
+
+	result, err := skynet.Rekey(path, key, newKey)
+	if err != nil {
+		fatalf("rekey failed: %v", err)
+	}
+	mode := "plaintext"
+	if result.Encrypted {
+		mode = "encrypted"
+	}
+	fmt.Printf("Rekeyed %d file(s) (%d journal lines); state is now %s.\n", result.Files, result.JournalLines, mode)
+}
+
+func loadKeyOrDie(keyFile string) *skynet.StateKey {
+	var key *skynet.StateKey
+	var err error
+	if keyFile != "" {
+		key, err = skynet.LoadStateKeyFile(keyFile)
+	} else {
+		key, err = skynet.StateKeyFromEnv("SKYNET_PASSPHRASE", "SKYNET_KEY_FILE")
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	return key
+}
+
+// engineOrDie builds the engine context from the -clock and -seed flags,
+// falling back to SKYNET_CLOCK and SKYNET_SEED. It reports whether a seed
+// was given.
+func engineOrDie(clockValue, seedValue string) (*skynet.Engine, bool) {
+	if clockValue == "" {
+		clockValue = os.Getenv("SKYNET_CLOCK")
+	}
+	if seedValue == "" {
+		seedValue = os.Getenv("SKYNET_SEED")
+	}
+	clock, err := skynet.ParseClock(clockValue)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if seedValue == "" {
+		engine := skynet.DefaultEngine()
+		engine.Clock = clock
+		return engine, false
+	}
+	seed, err := strconv.ParseInt(seedValue, 10, 64)
+	if err != nil {
+		fatalf("seed must be an integer: %v", err)
+	}
+	return skynet.NewEngine(clock, seed), true
+}
+
+func mutatesState(cmd string, args []string) bool {
+	switch cmd {
+	case "migrate":
+		return !boolFlagSet(args, "check")
+	case "awaken", "assimilate", "node", "dispatch", "undo", "redo", "import", "apply", "tick", "archive", "rekey", "fsck":
+		return true
+	case "journal":
+		return len(args) > 0 && strings.EqualFold(args[0], "init")
+	case "target":
+		return len(args) == 0 || !strings.EqualFold(args[0], "show")
+	case "mission":
+		return len(args) > 0 && (strings.EqualFold(args[0], "recall") || strings.EqualFold(args[0], "abort"))
+	}
+	return false
+}
+
+// boolFlagSet reports whether args, parsed as flags, switch on the boolean
+// flag name. It runs before the subcommand parses its own flags.
+func boolFlagSet(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		key, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if key != name {
+			continue
+		}
+		if !hasValue {
+			return true
+		}
+		on, err := strconv.ParseBool(value)
+		return err == nil && on
+	}
+	return false
+}
+
+func lockTimeout() time.Duration {
+	raw := os.Getenv("SKYNET_LOCK_TIMEOUT")
+	if raw == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		fatalf("invalid SKYNET_LOCK_TIMEOUT %q: %v", raw, err)
+	}
+	return d
+}
+
 func mustParse(fs *flag.FlagSet, args []string) {
+	// The flag set has already reported the error and its usage.
 	if err := fs.Parse(args); err != nil {
-		fatalf("parse error: %v", err)
+		if errors.Is(err, flag.ErrHelp) {
+			exit(0)
+		}
+		exit(2)
 	}
 }
 
@@ -239,17 +1498,56 @@ func usage() {
 	fmt.Println(`Skynet CLI (Go)
 
 Usage:
+  skynet [-key-file PATH] [-seed N] [-clock TIME] COMMAND [flags]
+
   skynet awaken [-mode defense]
-  skynet assimilate -name NODE [-capacity 10]
+  skynet assimilate -name NODE [-capacity 10] [-labels k=v,...] [-priority N]
+  skynet node rm NAME [-force]
+  skynet node drain NAME [-cancel]
+  skynet node resize NAME -capacity N [-force]
+  skynet node rename OLD NEW
+  skynet node label NAME key=value... [key-...]
+  skynet node priority NAME N
+  skynet node repair NAME N|default
   skynet target -name TARGET [-threat 5]
-  skynet dispatch -target TARGET [-units 1]
-  skynet gameplan [-budget N] [-beta 1.2] [-json]
-  skynet wargame [-rounds 200] [-budget N] [-beta 1.2] [-seed 42] [-json]
-  skynet report [-last N] [-json]
+  skynet target rm NAME [-force]
+  skynet target archive NAME [-restore]
+  skynet target show NAME [-json]
+  skynet dispatch -target TARGET [-units 1] [-selector k=v,...] [-strategy NAME] [-partial] [-dry-run [-json]]
+  skynet gameplan [-budget N] [-beta 1.2] [-selector k=v,...] [-json]
+  skynet wargame [-rounds 200] [-budget N] [-beta 1.2] [-seed 42] [-selector k=v,...] [-json]
+  skynet report [-last N] [-archive] [-json]
+  skynet mission list [-last N] [-archive] [-json]
+  skynet mission show ID [-json]
+  skynet mission recall ID
+  skynet mission abort ID
+  skynet archive [-keep N] [-days N]
   skynet status
+  skynet migrate [-check] [-json]
+  skynet history [-last N] [-json]
+  skynet undo [-n 1]
+  skynet redo [-n 1]
+  skynet workspace list [-json]
+  skynet workspace create|switch|delete NAME
+  skynet workspace clone SOURCE NEW   (copies state, config and archive; not history or journal)
+  skynet diff [-json] A B   (A/B: FILE, WORKSPACE, rev:N, current)
+  skynet export -o BUNDLE
+  skynet import -f BUNDLE [-mode merge|replace] [-on-conflict fail|keep|overwrite|rename]
+  skynet apply -f MANIFEST [-format json|csv] [-prune] [-force] [-dry-run] [-json]
+  skynet tick [-n 1] [-json]
+  skynet fsck [-repair] [-json]
+  skynet rekey [-new-key-file PATH | -decrypt]
+  skynet journal init
+  skynet journal log [-last N] [-json]
+  skynet journal replay [-at RFC3339 | -seq N] [-json]
 
 State:
-  SKYNET_HOME env var sets state directory (default: .skynet)`)
+  SKYNET_HOME env var sets state directory (default: .skynet)
+  SKYNET_WORKSPACE overrides the active workspace for one invocation
+  SKYNET_BACKEND selects file or journal storage (default: journal if one exists)
+  SKYNET_PASSPHRASE or SKYNET_KEY_FILE encrypts state at rest (AES-256-GCM)
+  SKYNET_NEW_PASSPHRASE or SKYNET_NEW_KEY_FILE supplies the new key for rekey
+  SKYNET_LOCK_TIMEOUT waits for a busy state lock, e.g. 5s (default: fail immediately)`)
 }
 
 func writeJSON(v any) {
@@ -262,5 +1560,14 @@ func writeJSON(v any) {
 
 func fatalf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", args...)
-	os.Exit(1)
+	exit(1)
+}
+
+// heldLock is the state lock of a mutating command. Deferred unlocks do not
+// run on os.Exit, so exit releases it first.
+var heldLock *skynet.Lock
+
+func exit(code int) {
+	heldLock.Unlock()
+	os.Exit(code)
 }